@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/vmporuri/prompt-and-paint/internal/game"
+)
+
+// The OpenAPI document describing the room API.
+var openAPIDocument = filepath.Join("api", "openapi.yaml")
+
+// The body of an error response from the room API.
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// Writes v as the JSON body of a response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("Error encoding API response: %v", err)
+	}
+}
+
+// Writes an error response with the given status code.
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, &apiError{Error: message})
+}
+
+// Gets the caller's token from the Authorization header or, for browsers, the userID cookie.
+// Cookie-authenticated requests that change anything must come from an allowed origin.
+func getAPIToken(r *http.Request) (string, error) {
+	authorization := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(authorization, "Bearer "); ok {
+		return token, nil
+	}
+	if authorization != "" {
+		return "", errors.New("Unsupported authorization scheme")
+	}
+	if r.Method != http.MethodGet && !isAllowedOrigin(r.Header.Get("origin")) {
+		return "", errors.New("Origin not allowed")
+	}
+	cookie, err := r.Cookie(jwtCookie)
+	if err != nil {
+		return "", errors.New("No userID cookie present")
+	}
+	return cookie.Value, nil
+}
+
+// Gets the userID of the caller from a bearer token or, for browsers, the userID cookie.
+func authenticateAPI(r *http.Request) (string, error) {
+	token, err := getAPIToken(r)
+	if err != nil {
+		return "", err
+	}
+	return parseUserIDToken(r.Context(), token)
+}
+
+// Writes the response for an error returned by the room API.
+func writeRoomError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, game.ErrRoomNotFound):
+		writeAPIError(w, http.StatusNotFound, "There is no room with that code")
+	case errors.Is(err, game.ErrNotHost):
+		writeAPIError(w, http.StatusForbidden, "Only the host can do that")
+	case errors.Is(err, game.ErrNotMember):
+		writeAPIError(w, http.StatusForbidden, "Only people in the room can see it")
+	default:
+		log.Printf("Error serving room API: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "Something went wrong")
+	}
+}
+
+// Gets the room code from the request path. Writes a 404 response if it is malformed.
+func roomCodeFromPath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	code := r.PathValue("code")
+	if !game.IsRoomCode(code) {
+		writeAPIError(w, http.StatusNotFound, "There is no room with that code")
+		return "", false
+	}
+	return code, true
+}
+
+// Creates a room hosted by the caller.
+func handleCreateRoom(w http.ResponseWriter, r *http.Request, userID string) {
+	status, err := game.CreateRoom(userID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/rooms/"+status.Code)
+	writeJSON(w, http.StatusCreated, status)
+}
+
+// Sends the status of a room to one of its members.
+func handleRoomStatus(w http.ResponseWriter, r *http.Request, code, userID string) {
+	status, err := game.GetRoomStatus(r.Context(), code, userID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// Closes a room on behalf of its host. Rooms close shortly after the request is accepted.
+func handleDeleteRoom(w http.ResponseWriter, r *http.Request, code, userID string) {
+	err := game.DeleteRoom(r.Context(), code, userID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Sends the leaderboard of a room to one of its members.
+func handleRoomLeaderboard(w http.ResponseWriter, r *http.Request, code, userID string) {
+	entries, err := game.GetLeaderboard(r.Context(), code, userID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// Sends the finished rounds of a room and their submissions to one of its members.
+func handleRoomRounds(w http.ResponseWriter, r *http.Request, code, userID string) {
+	rounds, err := game.ListRounds(r.Context(), code, userID)
+	if err != nil {
+		writeRoomError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rounds)
+}
+
+// Revokes the caller's token, so it can no longer be used, and clears the userID cookie.
+// The caller is given a new userID the next time they load the game.
+func handleRevokeToken(w http.ResponseWriter, r *http.Request, token *userToken) {
+	err := revokeUserToken(r.Context(), token)
+	if err != nil {
+		log.Printf("Error revoking token: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "Something went wrong")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     jwtCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Registers the room API endpoints, documented in api/openapi.yaml.
+func registerAPIRoutes(mux *http.ServeMux) {
+	// Handles GET requests for the OpenAPI document.
+	mux.HandleFunc("/api/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/yaml")
+		http.ServeFile(w, r, openAPIDocument)
+	})
+
+	// Handles DELETE requests to revoke the caller's token.
+	mux.HandleFunc("/api/token", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", http.MethodDelete)
+			writeAPIError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		tokenString, err := getAPIToken(r)
+		if err != nil {
+			writeAPIError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		token, err := parseUserToken(r.Context(), tokenString)
+		if err != nil {
+			writeAPIError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		handleRevokeToken(w, r, token)
+	})
+
+	// Handles POST requests to create a room.
+	mux.HandleFunc("/api/rooms", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeAPIError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		userID, err := authenticateAPI(r)
+		if err != nil {
+			writeAPIError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		handleCreateRoom(w, r, userID)
+	})
+
+	// Handles GET requests for a room's status and DELETE requests to close it.
+	mux.HandleFunc("/api/rooms/{code}", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+			w.Header().Set("Allow", "GET, DELETE")
+			writeAPIError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		userID, err := authenticateAPI(r)
+		if err != nil {
+			writeAPIError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		code, ok := roomCodeFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			handleDeleteRoom(w, r, code, userID)
+		} else {
+			handleRoomStatus(w, r, code, userID)
+		}
+	})
+
+	// Handles GET requests for a room's leaderboard.
+	mux.HandleFunc("/api/rooms/{code}/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeAPIError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		userID, err := authenticateAPI(r)
+		if err != nil {
+			writeAPIError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		code, ok := roomCodeFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		handleRoomLeaderboard(w, r, code, userID)
+	})
+
+	// Handles GET requests for a room's finished rounds.
+	mux.HandleFunc("/api/rooms/{code}/rounds", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeAPIError(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		userID, err := authenticateAPI(r)
+		if err != nil {
+			writeAPIError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		code, ok := roomCodeFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		handleRoomRounds(w, r, code, userID)
+	})
+}
@@ -1,50 +1,221 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"os"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 const (
-	jwtCookie   = "jwt"
-	userIDClaim = "userID"
+	jwtCookie    = "jwt"
+	tokenIssuer  = "prompt-and-paint"
+	tokenTTL     = time.Hour        // How long a token is valid after it is issued
+	tokenRenewal = 15 * time.Minute // How old a token gets before active users are sent a new one
 )
 
-// Makes a JSON web token associated with userID.
-// Signed with the key stored in environment variable "KEY".
+// A key that signs JSON web tokens, named in the "kid" header of the tokens it signs.
+type signingKey struct {
+	ID     string
+	Secret []byte
+}
+
+// The keys tokens are checked against. New tokens are signed with the current key.
+// Tokens signed with a previous key are still accepted and renewed with the current
+// key, so the key can be rotated without signing everyone out.
+var signingKeys struct {
+	current  signingKey
+	previous []signingKey
+}
+
+// The claims of a user's token. The token's ID is used to revoke it.
+type userClaims struct {
+	UserID string `json:"userID"`
+	jwt.RegisteredClaims
+}
+
+// A user's parsed and verified token.
+type userToken struct {
+	UserID    string
+	ID        string
+	KeyID     string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Creates a signing key whose ID is derived from its secret.
+func newSigningKey(secret string) signingKey {
+	sum := sha256.Sum256([]byte(secret))
+	return signingKey{ID: hex.EncodeToString(sum[:8]), Secret: []byte(secret)}
+}
+
+// Loads the signing keys from secrets "KEY" and "PREVIOUS_KEYS", a comma separated
+// list of retired keys that are still accepted. See loadSecret for where secrets are read from.
+// Errors if the key is missing or any key is too weak to sign tokens with.
+func setupSigningKeys() error {
+	secret, err := loadSecret("KEY")
+	if err != nil {
+		return err
+	}
+	if secret == "" {
+		return fmt.Errorf("No signing key is set, set KEY, KEY_FILE or the Docker secret key and %s", keyFormats)
+	}
+	err = checkKeyStrength(secret)
+	if err != nil {
+		return fmt.Errorf("KEY is too weak: %w", err)
+	}
+	previousSecrets, err := loadSecret("PREVIOUS_KEYS")
+	if err != nil {
+		return err
+	}
+
+	signingKeys.current = newSigningKey(secret)
+	signingKeys.previous = nil
+	for i, secret := range strings.Split(previousSecrets, ",") {
+		if secret = strings.TrimSpace(secret); secret == "" {
+			continue
+		}
+		err = checkKeyStrength(secret)
+		if err != nil {
+			return fmt.Errorf("Key %d of PREVIOUS_KEYS is too weak: %w", i+1, err)
+		}
+		signingKeys.previous = append(signingKeys.previous, newSigningKey(secret))
+	}
+	return nil
+}
+
+// Finds the signing key with the given ID. Returns false if no key has the ID.
+func findSigningKey(keyID string) (signingKey, bool) {
+	if keyID == "" {
+		return signingKey{}, false
+	}
+	if keyID == signingKeys.current.ID {
+		return signingKeys.current, true
+	}
+	for _, key := range signingKeys.previous {
+		if keyID == key.ID {
+			return key, true
+		}
+	}
+	return signingKey{}, false
+}
+
+// Makes a JSON web token associated with userID, valid for tokenTTL.
+// Signed with the current signing key.
+// Errors if the signing keys have not been loaded.
 func makeUserIDToken(userID string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		userIDClaim: userID,
+	if len(signingKeys.current.Secret) == 0 {
+		return "", errors.New("No signing key loaded")
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &userClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    tokenIssuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokenTTL)),
+		},
 	})
-	return token.SignedString([]byte(os.Getenv("KEY")))
+	token.Header["kid"] = signingKeys.current.ID
+	return token.SignedString(signingKeys.current.Secret)
 }
 
-// Parses the provided JSON web token and retrieves the associated userID.
-// Checks signature with the key stored in environment variable "KEY".
-// Errors if the MAC is incorrect or if the token does not have a userID field.
-func parseUserIDToken(tokenString string) (string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+// Parses the provided JSON web token and checks it against the signing key it names.
+// Errors if the MAC is incorrect, the token has expired or been revoked, or
+// the token is missing any of its claims.
+func parseUserToken(ctx context.Context, tokenString string) (*userToken, error) {
+	claims := &userClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		keyID, _ := token.Header["kid"].(string)
+		key, ok := findSigningKey(keyID)
+		if !ok {
+			return nil, fmt.Errorf("Unknown signing key: %v", token.Header["kid"])
 		}
+		return key.Secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID == "" {
+		return nil, errors.New("Could not get userID from claims")
+	}
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return nil, errors.New("Token is missing claims")
+	}
 
-		return []byte(os.Getenv("KEY")), nil
-	})
+	revoked, err := isTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("Token has been revoked")
+	}
+	keyID, _ := token.Header["kid"].(string)
+	return &userToken{
+		UserID:    claims.UserID,
+		ID:        claims.ID,
+		KeyID:     keyID,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// Parses the provided JSON web token and retrieves the associated userID.
+// Errors if the token is not valid.
+func parseUserIDToken(ctx context.Context, tokenString string) (string, error) {
+	token, err := parseUserToken(ctx, tokenString)
 	if err != nil {
 		return "", err
 	}
+	return token.UserID, nil
+}
+
+// Reports whether the token should be replaced, because it is getting old or
+// was signed with a previous key.
+func (t *userToken) needsRenewal() bool {
+	return time.Since(t.IssuedAt) > tokenRenewal || t.KeyID != signingKeys.current.ID
+}
+
+// Makes the cookie that holds a user's token.
+func userIDCookie(token string) *http.Cookie {
+	return &http.Cookie{
+		Name:     jwtCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(tokenTTL.Seconds()),
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", errors.New("Could not get claims")
+// Silently renews the user's token if it needs renewal, by adding the new cookie to
+// the response headers. Keeps active users signed in for as long as they keep playing.
+func renewUserToken(header http.Header, token *userToken) error {
+	if !token.needsRenewal() {
+		return nil
 	}
-	userIDInterface := claims[userIDClaim]
-	userID, ok := userIDInterface.(string)
-	if !ok {
-		return "", errors.New("Could not get userID from claims")
+	renewed, err := makeUserIDToken(token.UserID)
+	if err != nil {
+		return err
 	}
-	return userID, nil
+	header.Add("Set-Cookie", userIDCookie(renewed).String())
+	return nil
+}
+
+// Revokes a token for the rest of its lifetime.
+func revokeUserToken(ctx context.Context, token *userToken) error {
+	return revokeToken(ctx, token.ID, token.ExpiresAt)
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/vmporuri/prompt-and-paint/internal/chatbot"
+)
+
+// Connects the chat bot if the configuration names a chat service to post to.
+// The tokens are read from the environment variables named in the configuration.
+func setupChatBot(mux *http.ServeMux, cfg *Config) {
+	if cfg.ChatBot.PostURL == "" {
+		return
+	}
+	bridge, err := chatbot.NewBridge(chatbot.Config{
+		PostURL:      cfg.ChatBot.PostURL,
+		BotToken:     os.Getenv(cfg.ChatBot.BotTokenEnv),
+		CommandToken: os.Getenv(cfg.ChatBot.CommandTokenEnv),
+		BaseURL:      cfg.ChatBot.BaseURL,
+	})
+	if err != nil {
+		log.Fatalf("Error setting up chat bot: %v", err)
+	}
+
+	// Handles POST requests carrying the chat bot's slash commands.
+	mux.HandleFunc("/integrations/chat/commands", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		bridge.ServeHTTP(w, r)
+	})
+}
@@ -9,8 +9,9 @@ import (
 // Holds the configuration information for the Go server.
 type Config struct {
 	Server struct {
-		Port string `json:"port"`
-		Host string `json:"host"`
+		Port      string `json:"port"`
+		Host      string `json:"host"`
+		PublicURL string `json:"publicUrl"`
 	} `json:"server"`
 	Database struct {
 		RedisHost string `json:"redisHost"`
@@ -19,6 +20,17 @@ type Config struct {
 	Security struct {
 		AllowedOrigins []string `json:"allowedOrigins"`
 	} `json:"security"`
+	Webhooks []struct {
+		URL       string   `json:"url"`
+		Events    []string `json:"events"`
+		SecretEnv string   `json:"secretEnv"`
+	} `json:"webhooks"`
+	ChatBot struct {
+		PostURL         string `json:"postUrl"`
+		BaseURL         string `json:"baseUrl"`
+		CommandTokenEnv string `json:"commandTokenEnv"`
+		BotTokenEnv     string `json:"botTokenEnv"`
+	} `json:"chatBot"`
 }
 
 var cfg Config
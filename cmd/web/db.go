@@ -1,14 +1,41 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// The prefix of the keys that mark revoked tokens in the database.
+const revokedTokenPrefix = "revoked-token"
+
+var rdb *redis.Client
+
 // Creates a Redis database connection.
 func createDBConnection(cfg *Config) *redis.Client {
 	return redis.NewClient(&redis.Options{
 		Addr: fmt.Sprintf("%s:%s", cfg.Database.RedisHost, cfg.Database.RedisPort),
 	})
 }
+
+// Adds a token to the revocation list until it expires.
+// Errors if the database query errors.
+func revokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return rdb.Set(ctx, fmt.Sprintf("%s:%s", revokedTokenPrefix, tokenID), true, ttl).Err()
+}
+
+// Reports whether a token is on the revocation list.
+// Errors if the database query errors.
+func isTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	count, err := rdb.Exists(ctx, fmt.Sprintf("%s:%s", revokedTokenPrefix, tokenID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/google/uuid"
+	"github.com/vmporuri/prompt-and-paint/internal/game"
 )
 
 // Adds safe headers to HTTP responses.
@@ -18,6 +19,43 @@ func addSafeHeaders(w http.ResponseWriter) {
 	w.Header().Set("Cache-Control", "max-age=2592000")
 }
 
+// Holds data needed to create the home page from its template.
+// InviteCode is only set when the user followed an invite link.
+type indexPageData struct {
+	InviteCode string
+}
+
+// Gives the user a userID cookie if they don't already have a valid one,
+// and renews their cookie if it is getting old.
+func ensureUserCookie(w http.ResponseWriter, r *http.Request) {
+	userToken, err := getUserToken(r)
+	if err == nil {
+		err = renewUserToken(w.Header(), userToken)
+		if err != nil {
+			log.Printf("Error renewing token: %v", err)
+		}
+		return
+	}
+	token, err := makeUserIDToken(uuid.NewString())
+	if err != nil {
+		log.Printf("Error printing %v", err)
+		return
+	}
+	http.SetCookie(w, userIDCookie(token))
+}
+
+// Renders the home page.
+func renderIndex(w http.ResponseWriter, ipd *indexPageData) {
+	tmpl, err := template.ParseFiles(filepath.Join("templates", "index.html"))
+	if err != nil {
+		log.Fatalf("Error parsing template: %v", err)
+	}
+	err = tmpl.Execute(w, ipd)
+	if err != nil {
+		http.Error(w, "Unable to render template", http.StatusInternalServerError)
+	}
+}
+
 // Registers the API endpoints for the server.
 func registerRoutes(mux *http.ServeMux) {
 	// Handles GET requests to the top level path.
@@ -29,30 +67,68 @@ func registerRoutes(mux *http.ServeMux) {
 			return
 		}
 
-		_, err := r.Cookie(jwtCookie)
-		if err != nil {
-			token, err := makeUserIDToken(uuid.NewString())
-			if err != nil {
-				log.Printf("Error printing %v", err)
-			} else {
-				http.SetCookie(w, &http.Cookie{
-					Name:     jwtCookie,
-					Value:    token,
-					Path:     "/",
-					MaxAge:   3600,
-					Secure:   true,
-					SameSite: http.SameSiteStrictMode,
-				})
-			}
+		ensureUserCookie(w, r)
+		renderIndex(w, &indexPageData{})
+	})
+
+	// Handles GET requests to invite links, which join the room without the home page form.
+	mux.HandleFunc("/r/{code}", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		code := r.PathValue("code")
+		if !game.IsRoomCode(code) {
+			http.NotFound(w, r)
+			return
+		}
+		ensureUserCookie(w, r)
+		renderIndex(w, &indexPageData{InviteCode: code})
+	})
+
+	// Handles GET requests for the QR code of an invite link.
+	mux.HandleFunc("/r/{code}/qr.png", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
 		}
-		tmpl, err := template.ParseFiles(filepath.Join("templates", "index.html"))
+
+		code := r.PathValue("code")
+		if !game.IsRoomCode(code) {
+			http.NotFound(w, r)
+			return
+		}
+		png, err := inviteQRCode(r, code)
 		if err != nil {
-			log.Fatalf("Error parsing template: %v", err)
+			log.Printf("Error generating QR code: %v", err)
+			http.Error(w, "Unable to generate QR code", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	})
+
+	// Handles GET requests for pictures made by the offline image generator.
+	mux.HandleFunc("/pictures/fake/{id}", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
 		}
-		err = tmpl.Execute(w, nil)
+
+		svg, err := game.LookupFakePicture(r.Context(), r.PathValue("id"))
 		if err != nil {
-			http.Error(w, "Unable to render template", http.StatusInternalServerError)
+			http.NotFound(w, r)
+			return
 		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write(svg)
 	})
 
 	// Handles WebSocket upgrade requests to the game endpoint.
@@ -66,4 +142,29 @@ func registerRoutes(mux *http.ServeMux) {
 
 		handleWS(w, r)
 	})
+
+	// Handles GET requests for the Server-Sent Events stream, the fallback for
+	// networks that strip WebSocket upgrades.
+	mux.HandleFunc("/game/events", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		handleEvents(w, r)
+	})
+
+	// Handles POST requests carrying the messages of clients using the Server-Sent Events fallback.
+	mux.HandleFunc("/game/messages", func(w http.ResponseWriter, r *http.Request) {
+		addSafeHeaders(w)
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		handlePostMessage(w, r)
+	})
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// Builds the absolute invite link for a room from the configured public URL.
+// Players scan the link from another device, so it must not be relative.
+// The request's host and forwarded headers are only trusted when no public URL is set,
+// since any client can set them.
+func inviteURL(r *http.Request, code string) string {
+	if cfg.Server.PublicURL != "" {
+		return fmt.Sprintf("%s/r/%s", strings.TrimRight(cfg.Server.PublicURL, "/"), code)
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/r/%s", scheme, r.Host, code)
+}
+
+// Generates a PNG QR code that encodes the invite link for a room.
+func inviteQRCode(r *http.Request, code string) ([]byte, error) {
+	qrCode, err := qr.Encode(inviteURL(r, code), qr.M)
+	if err != nil {
+		return nil, err
+	}
+	qrCode.Scale = 8
+	return qrCode.PNG(), nil
+}
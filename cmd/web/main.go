@@ -12,10 +12,18 @@ import (
 func main() {
 	mux := http.NewServeMux()
 	registerRoutes(mux)
+	registerAPIRoutes(mux)
 
 	readConfig()
 	setupWSOriginCheck(&cfg)
-	game.SetupDBConnection(createDBConnection(&cfg))
+	err := setupSigningKeys()
+	if err != nil {
+		log.Fatalf("Error loading signing key: %v", err)
+	}
+	rdb = createDBConnection(&cfg)
+	game.SetupDBConnection(rdb)
+	setupWebhooks(&cfg)
+	setupChatBot(mux, &cfg)
 
 	log.Printf("Server listening on :%s", cfg.Server.Port)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", cfg.Server.Port), mux))
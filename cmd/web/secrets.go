@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The directory Docker mounts secrets in.
+const dockerSecretsDir = "/run/secrets"
+
+const (
+	minKeyLength  = 16  // The fewest characters a signing key can have
+	minKeyEntropy = 128 // The fewest bits of entropy a signing key can have, estimated from its alphabet
+)
+
+// Reads a secret from the environment variable name, the file named by the environment
+// variable name_FILE, or the Docker secret named after name in lower case, in that order.
+// Returns an empty string if the secret is not set anywhere.
+// Errors if a secret file is named but cannot be read.
+func loadSecret(name string) (string, error) {
+	if secret := os.Getenv(name); secret != "" {
+		return secret, nil
+	}
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("Unable to read %s_FILE: %w", name, err)
+		}
+		return strings.TrimSpace(string(secret)), nil
+	}
+	secret, err := os.ReadFile(filepath.Join(dockerSecretsDir, strings.ToLower(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("Unable to read Docker secret %s: %w", strings.ToLower(name), err)
+	}
+	return strings.TrimSpace(string(secret)), nil
+}
+
+// The characters of each alphabet keys are written in.
+const (
+	hexDigits     = "0123456789abcdefABCDEF"
+	lowerLetters  = "abcdefghijklmnopqrstuvwxyz"
+	upperLetters  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	decimalDigits = "0123456789"
+	base64Symbols = "+/-_"
+)
+
+// Describes the key formats that pass checkKeyStrength.
+const keyFormats = "use at least 32 hex characters (openssl rand -hex 16), at least 22 base64 " +
+	"characters (openssl rand -base64 16) or a longer mix of letters, digits and symbols"
+
+// Reports whether every character of the text is one of the given characters.
+func onlyContains(text, chars string) bool {
+	return strings.Trim(text, chars) == ""
+}
+
+// Guesses the number of characters in the alphabet a key was drawn from.
+// Hex keys use 16 characters. Other keys use every character class they contain,
+// so base64 keys come to 64 characters and keys with other symbols to 95.
+func keyAlphabetSize(secret string) int {
+	if onlyContains(secret, hexDigits) {
+		return 16
+	}
+	size := 0
+	if strings.ContainsAny(secret, lowerLetters) {
+		size += len(lowerLetters)
+	}
+	if strings.ContainsAny(secret, upperLetters) {
+		size += len(upperLetters)
+	}
+	if strings.ContainsAny(secret, decimalDigits) {
+		size += len(decimalDigits)
+	}
+	if !onlyContains(secret, lowerLetters+upperLetters+decimalDigits+base64Symbols) {
+		size += 33
+	} else if strings.ContainsAny(secret, base64Symbols) {
+		size += 2
+	}
+	return size
+}
+
+// Estimates the bits of entropy in a key as its length times the bits each
+// character carries in the key's alphabet. Base64 padding carries none.
+// Overestimates keys made of words, so it only catches keys that are too short.
+func estimateEntropy(secret string) float64 {
+	secret = strings.TrimRight(secret, "=")
+	if secret == "" {
+		return 0
+	}
+	return float64(len(secret)) * math.Log2(float64(keyAlphabetSize(secret)))
+}
+
+// Reports whether the key is a shorter string repeated, such as "abababab".
+func isRepetitive(secret string) bool {
+	for period := 1; period <= len(secret)/2; period++ {
+		if len(secret)%period == 0 && strings.Repeat(secret[:period], len(secret)/period) == secret {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks that a signing key is long and random enough that tokens cannot be forged.
+func checkKeyStrength(secret string) error {
+	if len(secret) < minKeyLength || estimateEntropy(secret) < minKeyEntropy {
+		return fmt.Errorf("The key must hold at least %d random bits, %s", minKeyEntropy, keyFormats)
+	}
+	if isRepetitive(secret) {
+		return fmt.Errorf("The key repeats itself, %s", keyFormats)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/vmporuri/prompt-and-paint/internal/game"
+)
+
+// How often an idle event stream sends a comment, so proxies don't time it out.
+const keepAliveInterval = 25 * time.Second
+
+// A Server-Sent Events stream to a client whose network strips WebSocket upgrades.
+// Closing the stream ends the HTTP response.
+type eventStream struct {
+	cancel context.CancelFunc
+}
+
+func (s *eventStream) Close() error {
+	s.cancel()
+	return nil
+}
+
+// Writes a single server-sent event. Each line of data is sent as its own data field.
+// Carriage returns also end lines in event streams, so they are normalized to newlines
+// first rather than letting text written by users end a field early.
+func writeEvent(w io.Writer, event string, data []byte) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "event: %s\n", event)
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		fmt.Fprintf(buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Streams messages to a client over Server-Sent Events, as a fallback for when the
+// WebSocket can't connect. The first event names the session the client posts its
+// messages to. Clients ask for the JSON protocol with the protocol query parameter.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	token, err := getUserToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	err = renewUserToken(w.Header(), token)
+	if err != nil {
+		log.Printf("Error renewing token: %v", err)
+	}
+	userID := token.UserID
+	protocol := game.HTMLProtocol
+	if r.URL.Query().Get("protocol") == game.JSONProtocol {
+		protocol = game.JSONProtocol
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	client := game.NewClient(&eventStream{cancel: cancel}, userID, protocol)
+	defer game.DispatchGameEvent(client, &game.GameMessage{Event: game.CloseWS})
+	sessionID, err := client.OpenSession()
+	if err != nil {
+		log.Printf("Error opening session: %v", err)
+		http.Error(w, "Unable to open session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	err = writeEvent(w, "session", []byte(sessionID))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case msg := <-client.WriteChan:
+			err := writeEvent(w, "message", msg)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			if err != nil {
+				log.Println(err)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// Accepts a GameMessage posted by a client using the Server-Sent Events fallback
+// and relays it to the client's session.
+func handlePostMessage(w http.ResponseWriter, r *http.Request) {
+	token, err := getUserToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !isAllowedOrigin(r.Header.Get("origin")) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	msg, err := io.ReadAll(http.MaxBytesReader(w, r.Body, game.MaxMessageSize))
+	if err != nil {
+		http.Error(w, "Message too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	err = renewUserToken(w.Header(), token)
+	if err != nil {
+		log.Printf("Error renewing token: %v", err)
+	}
+
+	err = game.PostToSession(r.Context(), r.URL.Query().Get("session"), token.UserID, msg)
+	switch {
+	case errors.Is(err, game.ErrUnknownSession):
+		http.Error(w, "Unknown session", http.StatusNotFound)
+	case errors.Is(err, game.ErrMalformedMessage):
+		http.Error(w, "Malformed message", http.StatusBadRequest)
+	case err != nil:
+		log.Printf("Error posting to session: %v", err)
+		http.Error(w, "Unable to deliver message", http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/vmporuri/prompt-and-paint/internal/game"
+)
+
+// Subscribes the webhooks in the configuration to game lifecycle events.
+// Each webhook's signing secret is read from the environment variable named by secretEnv.
+func setupWebhooks(cfg *Config) {
+	subscriptions := make([]game.WebhookSubscription, 0, len(cfg.Webhooks))
+	for _, webhook := range cfg.Webhooks {
+		secret := os.Getenv(webhook.SecretEnv)
+		if webhook.SecretEnv == "" || secret == "" {
+			log.Fatalf("Error missing secret for webhook %s", webhook.URL)
+		}
+		sub := game.WebhookSubscription{URL: webhook.URL, Secret: secret}
+		for _, event := range webhook.Events {
+			sub.Events = append(sub.Events, game.LifecycleEventType(event))
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+	err := game.SetupWebhooks(subscriptions)
+	if err != nil {
+		log.Fatalf("Error setting up webhooks: %v", err)
+	}
+}
@@ -13,75 +13,91 @@ import (
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	Subprotocols:    game.Subprotocols,
 }
 
 // Adds the origin check for the WebSocket upgrade request.
 // If the origin does not match, does not upgrade the connection.
 func setupWSOriginCheck(cfg *Config) {
 	upgrader.CheckOrigin = func(r *http.Request) bool {
-		origin := r.Header.Get("origin")
-		for _, allowed := range cfg.Security.AllowedOrigins {
-			if origin == allowed {
-				return true
-			}
+		return isAllowedOrigin(r.Header.Get("origin"))
+	}
+}
+
+// Reports whether the origin is one of the origins allowed in the configuration.
+func isAllowedOrigin(origin string) bool {
+	for _, allowed := range cfg.Security.AllowedOrigins {
+		if origin == allowed {
+			return true
 		}
-		return false
 	}
+	return false
 }
 
-// Gets the userID from the cookie in the HTTP request.
-// Errors if there is no cookie or the cookie is not formatted properly.
-func getCookie(r *http.Request) (string, error) {
+// Gets the user's token from the cookie in the HTTP request.
+// Errors if there is no cookie or the token in it is not valid.
+func getUserToken(r *http.Request) (*userToken, error) {
 	tokenString, err := r.Cookie(jwtCookie)
 	if err != nil {
-		return "", errors.New("No userID cookie present")
-	}
-	userID, err := parseUserIDToken(tokenString.Value)
-	if err != nil {
-		return "", err
+		return nil, errors.New("No userID cookie present")
 	}
-	return userID, nil
+	return parseUserToken(r.Context(), tokenString.Value)
 }
 
 // Sets up the WebSocket connection and begins reading from it.
 // Parses incoming messages as game events and processes via the game API.
+// Renews the user's cookie in the handshake if it is getting old.
 // Closes the read and write pump upon disconnection.
 func handleWS(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	var userID string
+	header := http.Header{}
+	token, err := getUserToken(r)
 	if err != nil {
 		log.Println(err)
-		return
+		userID = uuid.NewString()
+	} else {
+		userID = token.UserID
+		err = renewUserToken(header, token)
+		if err != nil {
+			log.Printf("Error renewing token: %v", err)
+		}
 	}
-	defer conn.Close()
 
-	userID, err := getCookie(r)
+	conn, err := upgrader.Upgrade(w, r, header)
 	if err != nil {
 		log.Println(err)
-		userID = uuid.NewString()
+		return
 	}
-	client := game.NewClient(conn, userID)
-	go writePump(client)
+	defer conn.Close()
+	conn.SetReadLimit(game.MaxMessageSize)
+
+	client := game.NewClient(conn, userID, conn.Subprotocol())
+	go writePump(conn, client)
 
 	for {
-		var gameMsg game.GameMessage
-		err := conn.ReadJSON(&gameMsg)
+		_, msg, err := conn.ReadMessage()
 		if err != nil {
 			log.Println(err)
 			game.DispatchGameEvent(client, &game.GameMessage{Event: game.CloseWS})
 			return
 		}
 
-		game.DispatchGameEvent(client, &gameMsg)
+		game.ReceiveMessage(client, msg)
 	}
 }
 
 // Continually checks for new messages to write to the WebSocket connection and sends
-// them as they come in.
-func writePump(client *game.Client) {
-	for msg := range client.WriteChan {
-		err := client.Conn.WriteMessage(websocket.TextMessage, msg)
-		if err != nil {
-			log.Println(err)
+// them as they come in, until the client disconnects.
+func writePump(conn *websocket.Conn, client *game.Client) {
+	for {
+		select {
+		case msg := <-client.WriteChan:
+			err := conn.WriteMessage(websocket.TextMessage, msg)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+		case <-client.Ctx.Done():
 			return
 		}
 	}
@@ -9,6 +9,7 @@ require (
 	github.com/lithammer/shortuuid v3.0.0+incompatible
 	github.com/redis/go-redis/v9 v9.5.3
 	github.com/sashabaranov/go-openai v1.26.1
+	rsc.io/qr v0.2.0
 )
 
 require (
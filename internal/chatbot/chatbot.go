@@ -0,0 +1,368 @@
+// Package chatbot runs Prompt and Paint games from a chat workspace. It answers
+// Slack and Discord style slash commands and posts each game's invite link,
+// round questions, final gallery and podium back to the channel that started it.
+package chatbot
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmporuri/prompt-and-paint/internal/game"
+)
+
+// How long the chat service has to accept a message.
+const postTimeout = 10 * time.Second
+
+// The number of places shown on the podium at the end of a match.
+const podiumSize = 3
+
+// Holds the settings of the chat bot.
+// PostURL is the chat service endpoint the bot posts channel messages to, sent
+// BotToken as a bearer token if it is set. Slash commands must carry CommandToken.
+// BaseURL is the public URL of the game, used to build invite links.
+type Config struct {
+	PostURL      string
+	BotToken     string
+	CommandToken string
+	BaseURL      string
+}
+
+// Connects the game to a chat workspace. Rooms started from a channel are owned
+// by that channel, so anyone in it can close them, and their lifecycle events
+// are posted back to it.
+type Bridge struct {
+	cfg      Config
+	games    games
+	client   *http.Client
+	mutex    sync.Mutex
+	channels map[string]string
+}
+
+// The parts of the game the bridge drives.
+type games interface {
+	// Creates a room owned by ownerID.
+	CreateOwnedRoom(ownerID, name string) (*game.RoomStatus, error)
+	// Describes a room userID may see.
+	GetRoomStatus(ctx context.Context, code, userID string) (*game.RoomStatus, error)
+	// Gets the leaderboard of a room userID may see.
+	GetLeaderboard(ctx context.Context, code, userID string) ([]game.LeaderboardEntry, error)
+	// Closes a room hosted or owned by userID.
+	DeleteRoom(ctx context.Context, code, userID string) error
+}
+
+// Runs the rooms on this server through the game package.
+type gameServer struct{}
+
+func (gameServer) CreateOwnedRoom(ownerID, name string) (*game.RoomStatus, error) {
+	return game.CreateOwnedRoom(ownerID, name)
+}
+
+func (gameServer) GetRoomStatus(ctx context.Context, code, userID string) (*game.RoomStatus, error) {
+	return game.GetRoomStatus(ctx, code, userID)
+}
+
+func (gameServer) GetLeaderboard(ctx context.Context, code, userID string) ([]game.LeaderboardEntry, error) {
+	return game.GetLeaderboard(ctx, code, userID)
+}
+
+func (gameServer) DeleteRoom(ctx context.Context, code, userID string) error {
+	return game.DeleteRoom(ctx, code, userID)
+}
+
+// A message the bot posts to a channel.
+type chatMessage struct {
+	Channel string `json:"channel"`
+	Text    string `json:"text"`
+}
+
+// The reply to a slash command. Ephemeral replies are only shown to whoever sent the command.
+type commandResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// Creates a bridge and starts posting the lifecycle events of its rooms.
+func NewBridge(cfg Config) (*Bridge, error) {
+	if cfg.PostURL == "" || cfg.CommandToken == "" || cfg.BaseURL == "" {
+		return nil, errors.New("The chat bot needs a post URL, a command token and a base URL")
+	}
+	b := newBridge(cfg, gameServer{})
+	game.OnLifecycleEvent(b.handleLifecycleEvent)
+	return b, nil
+}
+
+// Creates a bridge that runs its rooms through games.
+func newBridge(cfg Config, games games) *Bridge {
+	return &Bridge{
+		cfg:      cfg,
+		games:    games,
+		client:   &http.Client{Timeout: postTimeout},
+		channels: make(map[string]string),
+	}
+}
+
+// Gets the ID that owns rooms started from a channel.
+func channelOwner(channelID string) string {
+	return "chat:" + channelID
+}
+
+// Gets the link players follow to join a room.
+func (b *Bridge) inviteURL(code string) string {
+	return b.absoluteURL("/r/" + code)
+}
+
+// Resolves a path on the game's server, such as a picture it serves, against BaseURL.
+// Absolute URLs are returned as they are.
+func (b *Bridge) absoluteURL(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return path
+	}
+	return strings.TrimRight(b.cfg.BaseURL, "/") + path
+}
+
+// Remembers the channel a room was started from.
+func (b *Bridge) trackRoom(code, channelID string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.channels[code] = channelID
+}
+
+// Forgets a room. Returns the channel it was started from, or false if the
+// bridge did not start it.
+func (b *Bridge) untrackRoom(code string) (string, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	channelID, ok := b.channels[code]
+	delete(b.channels, code)
+	return channelID, ok
+}
+
+// Gets the channel a room was started from. Returns false if the bridge did not start it.
+func (b *Bridge) roomChannel(code string) (string, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	channelID, ok := b.channels[code]
+	return channelID, ok
+}
+
+// Handles a slash command, sent as a form with the command's text and the channel it was sent from.
+// Supports new, status, leaderboard and end.
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Malformed command", http.StatusBadRequest)
+		return
+	}
+	token := r.PostForm.Get("token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(b.cfg.CommandToken)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	channelID := r.PostForm.Get("channel_id")
+	if channelID == "" {
+		http.Error(w, "Missing channel", http.StatusBadRequest)
+		return
+	}
+
+	name, args, _ := strings.Cut(strings.TrimSpace(r.PostForm.Get("text")), " ")
+	args = strings.TrimSpace(args)
+	var resp *commandResponse
+	switch strings.ToLower(name) {
+	case "new":
+		resp = b.newRoom(channelID, args)
+	case "status":
+		resp = b.roomStatus(r.Context(), channelID, args)
+	case "leaderboard":
+		resp = b.leaderboard(r.Context(), channelID, args)
+	case "end":
+		resp = b.endRoom(r.Context(), channelID, args)
+	default:
+		resp = ephemeral(usage(r.PostForm.Get("command")))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		log.Printf("Error encoding command response: %v", err)
+	}
+}
+
+// Makes a reply only shown to whoever sent the command.
+func ephemeral(text string) *commandResponse {
+	return &commandResponse{ResponseType: "ephemeral", Text: text}
+}
+
+// Makes a reply shown to the whole channel.
+func inChannel(text string) *commandResponse {
+	return &commandResponse{ResponseType: "in_channel", Text: text}
+}
+
+// Explains the slash commands.
+func usage(command string) string {
+	if command == "" {
+		command = "/paint"
+	}
+	return fmt.Sprintf("Usage: `%[1]s new [room name]`, `%[1]s status CODE`, "+
+		"`%[1]s leaderboard CODE` or `%[1]s end CODE`", command)
+}
+
+// Describes an error returned by the game to the user who sent the command.
+func describeError(err error) *commandResponse {
+	switch {
+	case errors.Is(err, game.ErrRoomNotFound):
+		return ephemeral("There is no room with that code")
+	case errors.Is(err, game.ErrNotHost):
+		return ephemeral("Only the channel that started the room can end it")
+	case errors.Is(err, game.ErrNotMember):
+		return ephemeral("Only the channel that started the room can see it")
+	case errors.Is(err, game.ErrRoomName):
+		return ephemeral(err.Error())
+	default:
+		log.Printf("Error running chat command: %v", err)
+		return ephemeral("Something went wrong, please try again")
+	}
+}
+
+// Starts a room owned by the channel and shares its invite link.
+func (b *Bridge) newRoom(channelID, name string) *commandResponse {
+	status, err := b.games.CreateOwnedRoom(channelOwner(channelID), name)
+	if err != nil {
+		return describeError(err)
+	}
+	b.trackRoom(status.Code, channelID)
+	return inChannel(fmt.Sprintf("*%s* is open! Join with code *%s* at %s",
+		status.Name, status.Code, b.inviteURL(status.Code)))
+}
+
+// Describes what a room owned by the channel is doing.
+func (b *Bridge) roomStatus(ctx context.Context, channelID, code string) *commandResponse {
+	if !game.IsRoomCode(code) {
+		return ephemeral("Please give a room code")
+	}
+	status, err := b.games.GetRoomStatus(ctx, code, channelOwner(channelID))
+	if err != nil {
+		return describeError(err)
+	}
+	players := make([]string, 0, len(status.Players))
+	for _, player := range status.Players {
+		players = append(players, player.Username)
+	}
+	text := fmt.Sprintf("*%s* (%s): %s", status.Name, status.Code, status.Phase)
+	if len(players) > 0 {
+		text += "\nPlayers: " + strings.Join(players, ", ")
+	}
+	return ephemeral(text)
+}
+
+// Shows the leaderboard of a room owned by the channel.
+func (b *Bridge) leaderboard(ctx context.Context, channelID, code string) *commandResponse {
+	if !game.IsRoomCode(code) {
+		return ephemeral("Please give a room code")
+	}
+	entries, err := b.games.GetLeaderboard(ctx, code, channelOwner(channelID))
+	if err != nil {
+		return describeError(err)
+	}
+	if len(entries) == 0 {
+		return ephemeral("Nobody has scored yet")
+	}
+	return ephemeral(formatStandings(entries, len(entries)))
+}
+
+// Closes a room owned by the channel.
+func (b *Bridge) endRoom(ctx context.Context, channelID, code string) *commandResponse {
+	if !game.IsRoomCode(code) {
+		return ephemeral("Please give a room code")
+	}
+	err := b.games.DeleteRoom(ctx, code, channelOwner(channelID))
+	if err != nil {
+		return describeError(err)
+	}
+	return inChannel(fmt.Sprintf("Closing room *%s*", strings.ToUpper(code)))
+}
+
+// Lists the top places of a leaderboard, one player per line.
+func formatStandings(entries []game.LeaderboardEntry, places int) string {
+	lines := make([]string, 0, places)
+	for _, entry := range entries {
+		if entry.Rank > places {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("%d. %s (%d points)", entry.Rank, entry.Username, entry.Score))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Posts the lifecycle events of the bridge's rooms to the channels that started them.
+func (b *Bridge) handleLifecycleEvent(event *game.LifecycleEvent) {
+	var channelID string
+	var ok bool
+	if event.Type == game.MatchFinished || event.Type == game.RoomClosed {
+		channelID, ok = b.untrackRoom(event.RoomCode)
+	} else {
+		channelID, ok = b.roomChannel(event.RoomCode)
+	}
+	if !ok {
+		return
+	}
+
+	var text string
+	switch event.Type {
+	case game.RoundStarted:
+		text = fmt.Sprintf("Round %d in *%s*: %s", event.Round.Number, event.RoomCode, event.Round.Question)
+	case game.MatchFinished:
+		text = b.formatMatchResults(event)
+	default:
+		return
+	}
+	err := b.post(context.Background(), &chatMessage{Channel: channelID, Text: text})
+	if err != nil {
+		log.Printf("Error posting to chat: %v", err)
+	}
+}
+
+// Describes the end of a match: the podium, then the gallery of winning pictures.
+func (b *Bridge) formatMatchResults(event *game.LifecycleEvent) string {
+	text := fmt.Sprintf("The match in *%s* is over!\n%s", event.RoomCode, formatStandings(event.Standings, podiumSize))
+	if len(event.Gallery) > 0 {
+		text += "\nWinning pictures:"
+	}
+	for _, picture := range event.Gallery {
+		text += fmt.Sprintf("\nRound %d, %s: \"%s\" %s", picture.Round, picture.Username, picture.Prompt, b.absoluteURL(picture.URL))
+	}
+	return text
+}
+
+// Posts a message to the chat service.
+func (b *Bridge) post(ctx context.Context, msg *chatMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.cfg.PostURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.cfg.BotToken != "" {
+		req.Header.Set("Authorization", "Bearer "+b.cfg.BotToken)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("Unexpected response: " + resp.Status)
+	}
+	return nil
+}
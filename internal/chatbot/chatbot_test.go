@@ -0,0 +1,303 @@
+package chatbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vmporuri/prompt-and-paint/internal/game"
+)
+
+const testCommandToken = "command-token"
+
+// Runs rooms in memory for tests. Rooms are only visible to their owner.
+type fakeGames struct {
+	mutex        sync.Mutex
+	rooms        map[string]*game.RoomStatus
+	leaderboards map[string][]game.LeaderboardEntry
+}
+
+func newFakeGames() *fakeGames {
+	return &fakeGames{
+		rooms:        make(map[string]*game.RoomStatus),
+		leaderboards: make(map[string][]game.LeaderboardEntry),
+	}
+}
+
+func (g *fakeGames) CreateOwnedRoom(ownerID, name string) (*game.RoomStatus, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	status := &game.RoomStatus{Code: "BAKE", Name: name, OwnerID: ownerID, Phase: "Waiting for players"}
+	g.rooms[status.Code] = status
+	return status, nil
+}
+
+// Finds a room the user may see.
+func (g *fakeGames) findRoom(code, userID string) (*game.RoomStatus, error) {
+	status, ok := g.rooms[strings.ToUpper(code)]
+	if !ok {
+		return nil, game.ErrRoomNotFound
+	}
+	if status.OwnerID != userID {
+		return nil, game.ErrNotMember
+	}
+	return status, nil
+}
+
+func (g *fakeGames) GetRoomStatus(ctx context.Context, code, userID string) (*game.RoomStatus, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.findRoom(code, userID)
+}
+
+func (g *fakeGames) GetLeaderboard(ctx context.Context, code, userID string) ([]game.LeaderboardEntry, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	status, err := g.findRoom(code, userID)
+	if err != nil {
+		return nil, err
+	}
+	return g.leaderboards[status.Code], nil
+}
+
+func (g *fakeGames) DeleteRoom(ctx context.Context, code, userID string) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	status, ok := g.rooms[strings.ToUpper(code)]
+	if !ok {
+		return game.ErrRoomNotFound
+	}
+	if status.OwnerID != userID {
+		return game.ErrNotHost
+	}
+	delete(g.rooms, status.Code)
+	return nil
+}
+
+// Creates a bridge that runs its rooms on fakeGames and posts to postURL.
+func newTestBridge(postURL string) (*Bridge, *fakeGames) {
+	games := newFakeGames()
+	b := newBridge(Config{
+		PostURL:      postURL,
+		BotToken:     "bot-token",
+		CommandToken: testCommandToken,
+		BaseURL:      "https://paint.example.com/",
+	}, games)
+	return b, games
+}
+
+// Sends a slash command to the bridge and returns the recorded response.
+func sendCommand(b *Bridge, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/integrations/chat/commands", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	b.ServeHTTP(w, req)
+	return w
+}
+
+// Sends a slash command from a channel and decodes the bridge's reply.
+func runCommand(t *testing.T, b *Bridge, channelID, text string) *commandResponse {
+	t.Helper()
+	w := sendCommand(b, url.Values{
+		"token":      {testCommandToken},
+		"channel_id": {channelID},
+		"command":    {"/paint"},
+		"text":       {text},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("%q: got status %d, want %d", text, w.Code, http.StatusOK)
+	}
+	resp := &commandResponse{}
+	err := json.NewDecoder(w.Body).Decode(resp)
+	if err != nil {
+		t.Fatalf("%q: decoding reply: %v", text, err)
+	}
+	return resp
+}
+
+// Checks the type of a reply and that its text contains want.
+func checkReply(t *testing.T, resp *commandResponse, responseType, want string) {
+	t.Helper()
+	if resp.ResponseType != responseType {
+		t.Errorf("got %s reply %q, want %s", resp.ResponseType, resp.Text, responseType)
+	}
+	if !strings.Contains(resp.Text, want) {
+		t.Errorf("got reply %q, want it to contain %q", resp.Text, want)
+	}
+}
+
+func TestCommandRejectsBadToken(t *testing.T) {
+	b, _ := newTestBridge("http://127.0.0.1/unused")
+	w := sendCommand(b, url.Values{"token": {"wrong"}, "channel_id": {"C1"}, "text": {"new"}})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestCommandRequiresChannel(t *testing.T) {
+	b, _ := newTestBridge("http://127.0.0.1/unused")
+	w := sendCommand(b, url.Values{"token": {testCommandToken}, "text": {"new"}})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	b, _ := newTestBridge("http://127.0.0.1/unused")
+	w := sendCommand(b, url.Values{
+		"token":      {testCommandToken},
+		"channel_id": {"C1"},
+		"command":    {"/draw"},
+		"text":       {"help"},
+	})
+	resp := &commandResponse{}
+	err := json.NewDecoder(w.Body).Decode(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReply(t, resp, "ephemeral", "Usage: `/draw new [room name]`")
+}
+
+func TestNewCommand(t *testing.T) {
+	b, games := newTestBridge("http://127.0.0.1/unused")
+	resp := runCommand(t, b, "C1", "new Friday fun")
+	checkReply(t, resp, "in_channel", "*Friday fun* is open! Join with code *BAKE* at https://paint.example.com/r/BAKE")
+
+	if status := games.rooms["BAKE"]; status == nil || status.OwnerID != channelOwner("C1") {
+		t.Errorf("got room %+v, want it owned by channel C1", status)
+	}
+	if channelID, ok := b.roomChannel("BAKE"); !ok || channelID != "C1" {
+		t.Errorf("room is tracked for channel %q, want C1", channelID)
+	}
+}
+
+func TestStatusCommand(t *testing.T) {
+	b, games := newTestBridge("http://127.0.0.1/unused")
+	runCommand(t, b, "C1", "new Friday fun")
+	games.rooms["BAKE"].Players = []game.RoomMember{{Username: "ada"}, {Username: "grace"}}
+
+	checkReply(t, runCommand(t, b, "C1", "status bake"), "ephemeral", "*Friday fun* (BAKE): Waiting for players\nPlayers: ada, grace")
+	checkReply(t, runCommand(t, b, "C2", "status BAKE"), "ephemeral", "Only the channel that started the room can see it")
+	checkReply(t, runCommand(t, b, "C1", "status BODE"), "ephemeral", "There is no room with that code")
+	checkReply(t, runCommand(t, b, "C1", "status"), "ephemeral", "Please give a room code")
+}
+
+func TestLeaderboardCommand(t *testing.T) {
+	b, games := newTestBridge("http://127.0.0.1/unused")
+	runCommand(t, b, "C1", "new")
+
+	checkReply(t, runCommand(t, b, "C1", "leaderboard BAKE"), "ephemeral", "Nobody has scored yet")
+
+	games.leaderboards["BAKE"] = []game.LeaderboardEntry{
+		{Rank: 1, Username: "ada", Score: 5},
+		{Rank: 2, Username: "grace", Score: 3},
+	}
+	checkReply(t, runCommand(t, b, "C1", "leaderboard BAKE"), "ephemeral", "1. ada (5 points)\n2. grace (3 points)")
+	checkReply(t, runCommand(t, b, "C2", "leaderboard BAKE"), "ephemeral", "Only the channel that started the room can see it")
+}
+
+func TestEndCommand(t *testing.T) {
+	b, games := newTestBridge("http://127.0.0.1/unused")
+	runCommand(t, b, "C1", "new")
+
+	checkReply(t, runCommand(t, b, "C2", "end BAKE"), "ephemeral", "Only the channel that started the room can end it")
+	checkReply(t, runCommand(t, b, "C1", "end bake"), "in_channel", "Closing room *BAKE*")
+	if _, ok := games.rooms["BAKE"]; ok {
+		t.Error("room was not closed")
+	}
+}
+
+// A chat service stand-in that records the messages posted to it.
+type chatReceiver struct {
+	mutex    sync.Mutex
+	messages []chatMessage
+	auth     []string
+}
+
+// Starts a chat service stand-in. It is stopped when the test ends.
+func newChatReceiver(t *testing.T) (*chatReceiver, string) {
+	t.Helper()
+	receiver := &chatReceiver{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := chatMessage{}
+		err := json.NewDecoder(r.Body).Decode(&msg)
+		if err != nil {
+			t.Errorf("decoding posted message: %v", err)
+		}
+		receiver.mutex.Lock()
+		receiver.messages = append(receiver.messages, msg)
+		receiver.auth = append(receiver.auth, r.Header.Get("Authorization"))
+		receiver.mutex.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return receiver, server.URL
+}
+
+// Takes the messages posted since the last call.
+func (c *chatReceiver) take() []chatMessage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	messages := c.messages
+	c.messages = nil
+	return messages
+}
+
+func TestLifecycleEventsArePosted(t *testing.T) {
+	receiver, postURL := newChatReceiver(t)
+	b, _ := newTestBridge(postURL)
+	b.trackRoom("BAKE", "C1")
+
+	b.handleLifecycleEvent(&game.LifecycleEvent{
+		Type:     game.RoundStarted,
+		RoomCode: "BAKE",
+		Round:    &game.RoundRecord{Number: 1, Question: "What is the best pet?"},
+	})
+	messages := receiver.take()
+	if len(messages) != 1 || messages[0].Channel != "C1" || messages[0].Text != "Round 1 in *BAKE*: What is the best pet?" {
+		t.Fatalf("got messages %+v, want the round posted to C1", messages)
+	}
+	if receiver.auth[0] != "Bearer bot-token" {
+		t.Errorf("got authorization %q, want the bot token", receiver.auth[0])
+	}
+
+	b.handleLifecycleEvent(&game.LifecycleEvent{
+		Type:      game.MatchFinished,
+		RoomCode:  "BAKE",
+		Standings: []game.LeaderboardEntry{{Rank: 1, Username: "ada", Score: 5}},
+		Gallery:   []game.WinningPicture{{Round: 1, Username: "ada", Prompt: "a cat", URL: "/pictures/fake/cat"}},
+	})
+	messages = receiver.take()
+	want := "The match in *BAKE* is over!\n1. ada (5 points)\nWinning pictures:\n" +
+		"Round 1, ada: \"a cat\" https://paint.example.com/pictures/fake/cat"
+	if len(messages) != 1 || messages[0].Text != want {
+		t.Fatalf("got messages %+v, want the match results", messages)
+	}
+	if _, ok := b.roomChannel("BAKE"); ok {
+		t.Error("finished room is still tracked")
+	}
+
+	b.handleLifecycleEvent(&game.LifecycleEvent{Type: game.RoundStarted, RoomCode: "BAKE", Round: &game.RoundRecord{Number: 2}})
+	if messages := receiver.take(); len(messages) != 0 {
+		t.Errorf("got messages %+v for an untracked room", messages)
+	}
+}
+
+func TestClosedRoomIsUntracked(t *testing.T) {
+	receiver, postURL := newChatReceiver(t)
+	b, _ := newTestBridge(postURL)
+	b.trackRoom("BAKE", "C1")
+
+	b.handleLifecycleEvent(&game.LifecycleEvent{Type: game.RoomClosed, RoomCode: "BAKE"})
+	if _, ok := b.roomChannel("BAKE"); ok {
+		t.Error("closed room is still tracked")
+	}
+	if messages := receiver.take(); len(messages) != 0 {
+		t.Errorf("got messages %+v for a closed room", messages)
+	}
+}
@@ -0,0 +1,388 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Errors returned by the room API.
+var (
+	ErrRoomNotFound = errors.New("Room not found")
+	ErrNotHost      = errors.New("Only the host can do that")
+	ErrNotMember    = errors.New("Only people in the room can see it")
+	ErrRoomName     = fmt.Errorf("Room names can be at most %d characters", maxRoomNameLength)
+)
+
+// How long a room created through the API or quick match waits for someone to join before it is deleted.
+const unclaimedRoomTimeout = 10 * time.Minute
+
+// A user in a room, as served by the room API.
+type RoomMember struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+}
+
+// What a room is doing, who is in it and how it is set up, as served by the room API.
+// Backed up whenever the room's lobby listing is refreshed, so any server can serve it.
+type RoomStatus struct {
+	Code       string       `json:"code"`
+	Name       string       `json:"name"`
+	HostID     string       `json:"hostId"`
+	OwnerID    string       `json:"ownerId,omitempty"`
+	State      string       `json:"state"`
+	Phase      string       `json:"phase"`
+	Round      int          `json:"round"`
+	Open       bool         `json:"open"`
+	Players    []RoomMember `json:"players"`
+	Queued     []RoomMember `json:"queued"`
+	Spectators []RoomMember `json:"spectators"`
+	Displays   int          `json:"displays"`
+	Settings   roomSettings `json:"settings"`
+}
+
+// A single row of a room's leaderboard, as served by the room API.
+type LeaderboardEntry struct {
+	Rank     int    `json:"rank"`
+	Tied     bool   `json:"tied"`
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	Score    int    `json:"score"`
+}
+
+// A picture submitted in a finished round and the points its author scored.
+// In telephone rounds each link of a chain is a submission, and ChainOwnerID and Step
+// say which chain it belongs to and where.
+type SubmissionRecord struct {
+	UserID       string    `json:"userId"`
+	Username     string    `json:"username"`
+	URL          string    `json:"url,omitempty"`
+	Prompt       string    `json:"prompt"`
+	Points       int       `json:"points"`
+	ChainOwnerID string    `json:"chainOwnerId,omitempty"`
+	Step         int       `json:"step,omitempty"`
+	SubmittedAt  time.Time `json:"submittedAt"`
+}
+
+// A finished round, as served by the room API. WinnerID is empty if no picture got a vote.
+type RoundRecord struct {
+	Number           int                `json:"number"`
+	Question         string             `json:"question"`
+	QuestionAuthorID string             `json:"questionAuthorId,omitempty"`
+	WinnerID         string             `json:"winnerId,omitempty"`
+	Submissions      []SubmissionRecord `json:"submissions"`
+	StartedAt        time.Time          `json:"startedAt"`
+	EndedAt          time.Time          `json:"endedAt"`
+}
+
+// Lists the users in members ordered by username, then by userID.
+func sortedMembers(members map[string]string) []RoomMember {
+	sorted := make([]RoomMember, 0, len(members))
+	for userID, username := range members {
+		sorted = append(sorted, RoomMember{UserID: userID, Username: username})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Username != sorted[j].Username {
+			return sorted[i].Username < sorted[j].Username
+		}
+		return sorted[i].UserID < sorted[j].UserID
+	})
+	return sorted
+}
+
+// Builds the room's status.
+// Must be called with the room's mutex held.
+func (r *Room) getStatus() *RoomStatus {
+	status := &RoomStatus{
+		Code:       r.Code,
+		Name:       r.getListing().Name,
+		HostID:     r.Host,
+		OwnerID:    r.Owner,
+		State:      string(r.State),
+		Phase:      r.describePhase(),
+		Open:       r.acceptsPlayers(),
+		Players:    sortedMembers(r.getPlayers()),
+		Queued:     sortedMembers(r.getQueuedPlayers()),
+		Spectators: sortedMembers(r.Spectators),
+		Displays:   len(r.Displays),
+		Settings:   r.Settings,
+	}
+	if r.Round != nil {
+		status.Round = r.Round.Number
+	}
+	return status
+}
+
+// Backs up the room's status for the room API.
+func (r *Room) backupStatus(status *RoomStatus) error {
+	statusJSON, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return setRedisHash(r.Ctx, r.ID, string(roomStatus), statusJSON)
+}
+
+// Gets the key for the room's round history stored in the database.
+func (r *Room) getRoundHistoryKey() string {
+	return fmt.Sprintf("%s:%s", r.ID, roundHistory)
+}
+
+// Records a finished round in the room's round history and returns the record.
+// scores maps userIDs to the points they scored in the round.
+func (r *Room) recordRound(result *RoundResult, scores map[string]int) *RoundRecord {
+	record := &RoundRecord{
+		Number:           result.Number,
+		Question:         result.Question,
+		QuestionAuthorID: result.QuestionAuthorID,
+		Submissions:      make([]SubmissionRecord, 0, len(result.Submissions)),
+		StartedAt:        result.StartedAt,
+		EndedAt:          result.EndedAt,
+	}
+	if winner, ok := pickWinner(result); ok {
+		record.WinnerID = winner.UserID
+	}
+	for userID, submission := range result.Submissions {
+		record.Submissions = append(record.Submissions, SubmissionRecord{
+			UserID:      userID,
+			Username:    result.Players[userID],
+			URL:         submission.URL,
+			Prompt:      submission.Prompt,
+			Points:      scores[userID],
+			SubmittedAt: submission.SubmittedAt,
+		})
+	}
+	r.storeRoundRecord(record)
+	return record
+}
+
+// Adds a finished round to the room's round history, with its submissions in the
+// order they were made.
+func (r *Room) storeRoundRecord(record *RoundRecord) {
+	sort.Slice(record.Submissions, func(i, j int) bool {
+		return record.Submissions[i].SubmittedAt.Before(record.Submissions[j].SubmittedAt)
+	})
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Error encoding round record: %v", err)
+		return
+	}
+	_, err = pushRedisList(r.Ctx, r.getRoundHistoryKey(), recordJSON)
+	if err != nil {
+		log.Printf("Error recording round: %v", err)
+	}
+}
+
+// Closes the room on behalf of userID, who must be its host or owner.
+// Players are told the room was closed before it is deleted.
+func (r *Room) closeRoom(userID string) {
+	r.Mutex.RLock()
+	allowed := userID != "" && (r.Host == userID || r.Owner == userID)
+	r.Mutex.RUnlock()
+	if !allowed {
+		log.Printf("Error %s cannot close room %s", userID, r.ID)
+		return
+	}
+	closeMsg, err := json.Marshal(newPSMessage(roomClosed, r.ID, ""))
+	if err != nil {
+		log.Printf("Error encoding close room message: %v", err)
+	} else {
+		err = publishRoomMessage(r, closeMsg)
+		if err != nil {
+			log.Printf("Error publishing close room message: %v", err)
+		}
+	}
+	r.deleteRoom()
+}
+
+// Deletes a room created through the API if nobody joined it in time.
+func (r *Room) deleteIfUnclaimed() {
+	if r.Ctx.Err() != nil {
+		return
+	}
+	r.Mutex.RLock()
+	unclaimed := len(r.Players) == 0 && len(r.Queue) == 0 &&
+		len(r.Spectators) == 0 && len(r.Displays) == 0
+	r.Mutex.RUnlock()
+	if unclaimed {
+		r.deleteRoom()
+	}
+}
+
+// Tells the client the host closed its room and ends the connection.
+func (c *Client) handleRoomClosed() {
+	c.sendNotice("The host closed the room")
+	c.handleLeave()
+}
+
+// Creates a room hosted by hostID and returns its status. The host joins the
+// room like any other player, for example by following its invite link.
+// Rooms nobody joins are deleted after a while.
+func CreateRoom(hostID string) (*RoomStatus, error) {
+	room, err := createRoom(hostID)
+	if err != nil {
+		return nil, err
+	}
+	time.AfterFunc(unclaimedRoomTimeout, room.deleteIfUnclaimed)
+	room.Mutex.RLock()
+	defer room.Mutex.RUnlock()
+	return room.getStatus(), nil
+}
+
+// Creates a room owned by ownerID, such as a chat integration, and returns its status.
+// The owner may close the room but does not play in it, so the first player to join
+// becomes the host. Rooms nobody joins are deleted after a while.
+func CreateOwnedRoom(ownerID, name string) (*RoomStatus, error) {
+	name, err := parseRoomName(name)
+	if err != nil {
+		return nil, ErrRoomName
+	}
+	room := newRoom("")
+	room.Owner = ownerID
+	room.Settings.Name = name
+	err = room.open()
+	if err != nil {
+		return nil, err
+	}
+	time.AfterFunc(unclaimedRoomTimeout, room.deleteIfUnclaimed)
+	room.Mutex.RLock()
+	defer room.Mutex.RUnlock()
+	return room.getStatus(), nil
+}
+
+// Looks up the room with the given code. Returns ErrRoomNotFound if there is none.
+func findRoom(ctx context.Context, code string) (string, error) {
+	roomID, exists, err := roomRepo.resolveCode(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		return "", ErrRoomNotFound
+	}
+	return roomID, nil
+}
+
+// Fetches the status backed up by the room with the given roomID.
+func fetchRoomStatus(ctx context.Context, roomID string) (*RoomStatus, error) {
+	statusJSON, err := getRedisHash(ctx, roomID, string(roomStatus))
+	if err == redis.Nil {
+		return nil, ErrRoomNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	status := &RoomStatus{}
+	err = json.Unmarshal([]byte(statusJSON), status)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// Reports whether the user is the room's host or owner, or is playing, queued or watching in it.
+func (s *RoomStatus) hasMember(userID string) bool {
+	if userID == "" {
+		return false
+	}
+	if s.HostID == userID || s.OwnerID == userID {
+		return true
+	}
+	for _, members := range [][]RoomMember{s.Players, s.Queued, s.Spectators} {
+		for _, member := range members {
+			if member.UserID == userID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Finds the room with the given code and fetches its status on behalf of userID.
+// Returns ErrNotMember unless the user is in the room, or is its host or owner.
+func fetchMemberRoomStatus(ctx context.Context, code, userID string) (string, *RoomStatus, error) {
+	roomID, err := findRoom(ctx, code)
+	if err != nil {
+		return "", nil, err
+	}
+	status, err := fetchRoomStatus(ctx, roomID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !status.hasMember(userID) {
+		return "", nil, ErrNotMember
+	}
+	return roomID, status, nil
+}
+
+// Gets the status of the room with the given code for one of its members.
+func GetRoomStatus(ctx context.Context, code, userID string) (*RoomStatus, error) {
+	_, status, err := fetchMemberRoomStatus(ctx, code, userID)
+	return status, err
+}
+
+// Gets the leaderboard of the room with the given code for one of its members.
+// Only players still in the room are ranked.
+func GetLeaderboard(ctx context.Context, code, userID string) ([]LeaderboardEntry, error) {
+	roomID, status, err := fetchMemberRoomStatus(ctx, code, userID)
+	if err != nil {
+		return nil, err
+	}
+	members, err := getRedisSortedSetWithScores(ctx, fmt.Sprintf("%s:%s", roomID, leaderboard))
+	if err != nil {
+		return nil, err
+	}
+	players := make(map[string]string, len(status.Players))
+	for _, player := range status.Players {
+		players[player.UserID] = player.Username
+	}
+	return publicLeaderboard(rankLeaderboard(members, players, nil, nil)), nil
+}
+
+// Lists the finished rounds of the room with the given code for one of its members, oldest first.
+// Telephone rounds list the links of their chains as submissions.
+func ListRounds(ctx context.Context, code, userID string) ([]RoundRecord, error) {
+	roomID, _, err := fetchMemberRoomStatus(ctx, code, userID)
+	if err != nil {
+		return nil, err
+	}
+	recordsJSON, err := getRedisList(ctx, fmt.Sprintf("%s:%s", roomID, roundHistory))
+	if err != nil {
+		return nil, err
+	}
+	records := make([]RoundRecord, 0, len(recordsJSON))
+	for _, recordJSON := range recordsJSON {
+		record := RoundRecord{}
+		err := json.Unmarshal([]byte(recordJSON), &record)
+		if err != nil {
+			log.Printf("Error parsing round record: %v", err)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Asks the room with the given code to close. Only the host or owner may close a room.
+// The room is deleted by whichever server runs it, so it may briefly outlive the call.
+func DeleteRoom(ctx context.Context, code, userID string) error {
+	roomID, err := findRoom(ctx, code)
+	if err != nil {
+		return err
+	}
+	status, err := fetchRoomStatus(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	if userID == "" || (status.HostID != userID && status.OwnerID != userID) {
+		return ErrNotHost
+	}
+	closeMsg, err := json.Marshal(newPSMessage(closeRoom, userID, ""))
+	if err != nil {
+		return err
+	}
+	return publishChannelMessage(ctx, roomID, closeMsg)
+}
@@ -0,0 +1,278 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The emoji players and spectators can react with.
+var reactionEmoji = []string{"👍", "😂", "😍", "😮", "🔥", "👏"}
+
+const (
+	chatHistoryLength = 50               // The number of recent messages a room keeps for reconnecting clients
+	chatRateWindow    = 10 * time.Second // The window rate limits are counted over
+	chatRateLimit     = 5                // The most chat messages a user can send in each window
+	reactionRateLimit = 10               // The most reactions a user can send in each window
+)
+
+// Shown to users who send chat messages or reactions too quickly.
+var errChatRateLimited = errors.New("You're sending messages too quickly, please wait a moment")
+
+// A chat message as relayed to the room and kept in its history.
+// Own is set for the client that sent the message.
+type chatEntry struct {
+	ID        string
+	UserID    string
+	Username  string
+	Text      string
+	Spectator bool
+	Time      time.Time
+	Own       bool `json:"-"`
+}
+
+// An emoji reaction as relayed to the room. CandidateID is set for reactions
+// to a picture on the voting page.
+type reactionEntry struct {
+	UserID      string
+	Username    string
+	Emoji       string
+	CandidateID string
+}
+
+// Holds data needed to create the chat panel from its template.
+type chatPanelData struct {
+	Messages  []chatEntry
+	Reactions []string
+	MaxLength int
+}
+
+// Counts a chat message or reaction against the client's rate limit.
+// Returns an error the client can be shown once the limit is reached.
+func (c *Client) checkChatRate(event gameEvent, limit int64) error {
+	key := fmt.Sprintf("%s:%s:%s", chatRate, event, c.UserID)
+	count, err := incrRedisCounter(c.Ctx, key, chatRateWindow)
+	if err != nil {
+		return err
+	}
+	if count > limit {
+		return errChatRateLimited
+	}
+	return nil
+}
+
+// Sends the client's chat message to its room.
+func (c *Client) handleChat(p *chatPayload) {
+	if !c.inChat() {
+		return
+	}
+	err := c.checkChatRate(chat, chatRateLimit)
+	if errors.Is(err, errChatRateLimited) {
+		c.sendRejection(chat, err)
+		return
+	} else if err != nil {
+		log.Printf("Error checking chat rate limit: %v", err)
+		return
+	}
+	chatMsg, err := json.Marshal(newPSMessage(chat, c.UserID, p.Text))
+	if err != nil {
+		log.Printf("Error encoding chat message: %v", err)
+		return
+	}
+	err = publishClientMessage(c, chatMsg)
+	if err != nil {
+		log.Printf("Error publishing chat message: %v", err)
+	}
+}
+
+// Sends the client's reaction to its room.
+func (c *Client) handleReaction(p *reactionPayload) {
+	if !c.inChat() {
+		return
+	}
+	err := c.checkChatRate(react, reactionRateLimit)
+	if errors.Is(err, errChatRateLimited) {
+		c.sendRejection(react, err)
+		return
+	} else if err != nil {
+		log.Printf("Error checking reaction rate limit: %v", err)
+		return
+	}
+	reactionJSON, err := json.Marshal(&reactionEntry{Emoji: p.Emoji, CandidateID: p.CandidateID})
+	if err != nil {
+		log.Printf("Error encoding reaction: %v", err)
+		return
+	}
+	reactionMsg, err := json.Marshal(newPSMessage(react, c.UserID, string(reactionJSON)))
+	if err != nil {
+		log.Printf("Error encoding reaction message: %v", err)
+		return
+	}
+	err = publishClientMessage(c, reactionMsg)
+	if err != nil {
+		log.Printf("Error publishing reaction: %v", err)
+	}
+}
+
+// Gets the key for the room's chat history stored in the database.
+func (r *Room) getChatKey() string {
+	return fmt.Sprintf("%s:%s", r.ID, chatHistory)
+}
+
+// Finds the name a user chats under and whether they are a spectator.
+// Players, queued players and spectators can chat. Returns false if the user is not in the room.
+// Must be called with the room's mutex held.
+func (r *Room) chatName(userID string) (string, bool, bool) {
+	if username, ok := r.Players[userID]; ok {
+		return username, false, true
+	}
+	if username, ok := r.getQueuedPlayers()[userID]; ok {
+		return username, false, true
+	}
+	username, ok := r.Spectators[userID]
+	return username, true, ok
+}
+
+// Filters a user's chat message, adds it to the room's history and relays it to the room.
+func (r *Room) handleChat(userID, text string) {
+	r.Mutex.RLock()
+	username, spectator, ok := r.chatName(userID)
+	r.Mutex.RUnlock()
+	if !ok {
+		log.Printf("Error %s cannot chat in room %s", userID, r.ID)
+		return
+	}
+	entry := &chatEntry{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Username:  username,
+		Text:      censorChat(text),
+		Spectator: spectator,
+		Time:      time.Now(),
+	}
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Error encoding chat entry: %v", err)
+		return
+	}
+	err = pushCappedRedisList(r.Ctx, r.getChatKey(), entryJSON, chatHistoryLength)
+	if err != nil {
+		log.Printf("Error backing up chat message: %v", err)
+	}
+	chatMsg, err := json.Marshal(newPSMessage(newChatMessage, r.ID, string(entryJSON)))
+	if err != nil {
+		log.Printf("Error encoding chat message: %v", err)
+		return
+	}
+	err = publishRoomMessage(r, chatMsg)
+	if err != nil {
+		log.Printf("Error publishing chat message: %v", err)
+	}
+}
+
+// Relays a user's reaction to the room. Reactions to a picture are only
+// relayed while the room is voting on it.
+func (r *Room) handleReaction(userID, reactionJSON string) {
+	reaction := &reactionEntry{}
+	err := json.Unmarshal([]byte(reactionJSON), reaction)
+	if err != nil {
+		log.Printf("Error parsing reaction: %v", err)
+		return
+	}
+	r.Mutex.RLock()
+	username, _, ok := r.chatName(userID)
+	onBallot := r.State == voting && r.Round != nil &&
+		slices.ContainsFunc(r.Round.Candidates, func(candidate Candidate) bool {
+			return candidate.ID == reaction.CandidateID
+		})
+	r.Mutex.RUnlock()
+	if !ok || (reaction.CandidateID != "" && !onBallot) {
+		return
+	}
+	reaction.UserID = userID
+	reaction.Username = username
+
+	relayJSON, err := json.Marshal(reaction)
+	if err != nil {
+		log.Printf("Error encoding reaction: %v", err)
+		return
+	}
+	reactionMsg, err := json.Marshal(newPSMessage(newReaction, r.ID, string(relayJSON)))
+	if err != nil {
+		log.Printf("Error encoding reaction message: %v", err)
+		return
+	}
+	err = publishRoomMessage(r, reactionMsg)
+	if err != nil {
+		log.Printf("Error publishing reaction: %v", err)
+	}
+}
+
+// Reports whether the client has joined its room's chat, either as a player or
+// as a spectator. Displays only show reactions to pictures.
+func (c *Client) inChat() bool {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	return c.RoomID != "" && c.Username != "" && !c.Display
+}
+
+// Sends the chat panel with the room's recent messages, oldest first.
+// Used when the client joins or reconnects to a room.
+func (c *Client) sendChatPanel() {
+	historyJSON, err := getRedisList(c.Ctx, fmt.Sprintf("%s:%s", c.RoomID, chatHistory))
+	if err != nil {
+		log.Printf("Error fetching chat history: %v", err)
+	}
+	cpd := &chatPanelData{
+		Messages:  make([]chatEntry, 0, len(historyJSON)),
+		Reactions: reactionEmoji,
+		MaxLength: maxChatLength,
+	}
+	for i := len(historyJSON) - 1; i >= 0; i-- {
+		entry := chatEntry{}
+		err := json.Unmarshal([]byte(historyJSON[i]), &entry)
+		if err != nil {
+			log.Printf("Error parsing chat entry: %v", err)
+			continue
+		}
+		entry.Own = entry.UserID == c.UserID
+		cpd.Messages = append(cpd.Messages, entry)
+	}
+	err = sendPage(c, chatPanel, cpd, generateChatPanel)
+	if err != nil {
+		log.Printf("Error creating chat panel template: %v", err)
+	}
+}
+
+// Shows a chat message relayed by the room.
+func (c *Client) displayChatMessage(msg string) {
+	if !c.inChat() {
+		return
+	}
+	err := c.sendView(newChatMessage, msg)
+	if err != nil {
+		log.Printf("Error creating chat message template: %v", err)
+	}
+}
+
+// Shows a reaction relayed by the room. Displays only show reactions to pictures.
+func (c *Client) displayReaction(msg string) {
+	if c.isDisplay() {
+		reaction := &reactionEntry{}
+		err := json.Unmarshal([]byte(msg), reaction)
+		if err != nil || reaction.CandidateID == "" {
+			return
+		}
+	} else if !c.inChat() {
+		return
+	}
+	err := c.sendView(newReaction, msg)
+	if err != nil {
+		log.Printf("Error creating reaction template: %v", err)
+	}
+}
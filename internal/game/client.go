@@ -6,44 +6,108 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"strconv"
 	"sync"
 
-	"github.com/gorilla/websocket"
 	"github.com/redis/go-redis/v9"
 )
 
-// Represents a single user of the website. Associated with one WebSocket connection.
+// Represents a single user of the website. Associated with one connection, which is
+// a WebSocket unless the client fell back to Server-Sent Events.
 // Acts as a middle-man for all incoming and outgoing messages.
 // Uniquely identified by UserID. Username does not have to be unique.
 // Connected to room specified by RoomID and communicates with Pubsub.
 type Client struct {
-	Conn      *websocket.Conn
-	UserID    string
-	Username  string
-	RoomID    string
-	Pubsub    *redis.PubSub
-	Mutex     *sync.Mutex
-	WriteChan chan []byte
-	Ctx       context.Context
-	Cancel    context.CancelFunc
+	Conn         io.Closer
+	UserID       string
+	Username     string
+	RoomID       string
+	Pubsub       *redis.PubSub
+	Mutex        *sync.Mutex
+	WriteChan    chan []byte
+	Assignment   string
+	Spectator    bool
+	Queued       bool
+	Display      bool
+	Protocol     string
+	StopMatching context.CancelFunc
+	Ctx          context.Context
+	Cancel       context.CancelFunc
+}
+
+// Queues a message to be written to the client's connection.
+// Messages written after the client disconnects are dropped.
+func (c *Client) write(msg []byte) {
+	select {
+	case c.WriteChan <- msg:
+	case <-c.Ctx.Done():
+	}
 }
 
 // A data structure that holds user input in the game.
 // Matches the structure of HTMX WebSocket messages and form data specified in the templates.
+// Any form inputs other than event and msg are collected in Fields by input name.
+// JSON clients send the same shape, optionally with the protocol version as v.
 type GameMessage struct {
-	Headers map[string]any `json:"HEADERS"`
-	Event   gameEvent      `json:"event"`
-	Msg     string         `json:"msg"`
+	Headers map[string]any      `json:"HEADERS"`
+	Event   gameEvent           `json:"event"`
+	Msg     string              `json:"msg"`
+	Fields  map[string][]string `json:"-"`
+}
+
+// Decodes a GameMessage, collecting any extra form inputs into Fields.
+// HTMX sends a single value for an input as a string and repeated inputs
+// (such as checkboxes) as an array of strings.
+func (m *GameMessage) UnmarshalJSON(data []byte) error {
+	type gameMessage GameMessage
+	err := json.Unmarshal(data, (*gameMessage)(m))
+	if err != nil {
+		return err
+	}
+
+	raw := make(map[string]json.RawMessage)
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	m.Fields = make(map[string][]string, len(raw))
+	for name, value := range raw {
+		switch name {
+		case "HEADERS", "event", "msg":
+			continue
+		case "v":
+			err := checkProtocolVersion(value)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		var single string
+		if json.Unmarshal(value, &single) == nil {
+			m.Fields[name] = []string{single}
+			continue
+		}
+		var multiple []string
+		if json.Unmarshal(value, &multiple) == nil {
+			m.Fields[name] = multiple
+			continue
+		}
+		return fmt.Errorf("Unexpected value for form field %s", name)
+	}
+	return nil
 }
 
-// Creates a new client with the provided userID.
+// Creates a new client with the provided userID, which talks over the given protocol.
 // Automatically reconnects to game if existing userID is found.
-func NewClient(conn *websocket.Conn, userID string) *Client {
+func NewClient(conn io.Closer, userID, protocol string) *Client {
 	ctx, cancel := context.WithCancel(context.Background())
 	client := &Client{
 		Conn:      conn,
 		UserID:    userID,
+		Protocol:  protocol,
 		WriteChan: make(chan []byte),
 		Mutex:     &sync.Mutex{},
 		Ctx:       ctx,
@@ -61,6 +125,18 @@ func NewClient(conn *websocket.Conn, userID string) *Client {
 	}
 
 	client.Username = username
+	isSpectating, err := getRedisHash(client.Ctx, userID, string(spectating))
+	if err == nil {
+		client.Spectator, _ = strconv.ParseBool(isSpectating)
+	}
+	isQueued, err := getRedisHash(client.Ctx, userID, string(queuedStatus))
+	if err == nil {
+		client.Queued, _ = strconv.ParseBool(isQueued)
+	}
+	isDisplay, err := getRedisHash(client.Ctx, userID, string(displaying))
+	if err == nil {
+		client.Display, _ = strconv.ParseBool(isDisplay)
+	}
 	err = client.joinRoom(roomID)
 	if err != nil {
 		log.Printf("Error unable to reconnect to room: %v", err)
@@ -73,12 +149,23 @@ func NewClient(conn *websocket.Conn, userID string) *Client {
 }
 
 // Reconnects client to the room by updating room's information and fetching room state.
+// Players still waiting for the next round rejoin the queue, and displays are shown the
+// room again as if they had just joined.
 func (c *Client) reconnectClient() error {
+	if c.isDisplay() {
+		go c.startDisplaying()
+		return nil
+	}
 	reconnectionMessage := &PSMessage{
 		Event:  reconnect,
 		Sender: c.UserID,
 		Msg:    c.Username,
 	}
+	if c.Spectator {
+		reconnectionMessage.Event = newSpectator
+	} else if c.Queued {
+		reconnectionMessage.Event = newUser
+	}
 	reconnectionJSON, err := json.Marshal(reconnectionMessage)
 	if err != nil {
 		return err
@@ -87,13 +174,26 @@ func (c *Client) reconnectClient() error {
 	if err != nil {
 		return err
 	}
+	go c.sendChatPanel()
+	return c.restoreView()
+}
+
+// Sends the client its view of the page the room is currently on.
+func (c *Client) restoreView() error {
 	roomState, err := c.fetchRoomState()
 	if err != nil {
 		return errors.New("Unable to fetch current room state")
 	}
-	go func() {
-		c.WriteChan <- []byte(roomState)
-	}()
+	page := PSMessage{}
+	err = json.Unmarshal([]byte(roomState), &page)
+	if err != nil {
+		return err
+	}
+	view, err := c.encodeView(page.Event, page.Msg)
+	if err != nil {
+		return err
+	}
+	go c.write(view)
 	return nil
 }
 
@@ -119,23 +219,64 @@ func (c *Client) fetchRoomState() (string, error) {
 	return getRedisHash(c.Ctx, c.RoomID, string(roomBackup))
 }
 
-// Dispatches the appropriate event handler for the given gameMsg.
+// Dispatches the appropriate event handler for the given gameMsg once its payload is
+// decoded. Malformed messages are rejected with an error shown to the client.
+// Spectators and queued players may only send the events that don't act on the game,
+// and displays may only move between rooms.
 func DispatchGameEvent(client *Client, gameMsg *GameMessage) {
+	if client.isDisplay() && !displayEvents[gameMsg.Event] {
+		log.Printf("Error display %s cannot send %s", client.UserID, gameMsg.Event)
+		return
+	}
+	if client.isWatching() && !watchingEvents[gameMsg.Event] {
+		log.Printf("Error spectator %s cannot send %s", client.UserID, gameMsg.Event)
+		return
+	}
+	p, err := parsePayload(gameMsg)
+	if err != nil {
+		log.Printf("Error rejected %s from %s: %v", gameMsg.Event, client.UserID, err)
+		go client.sendRejection(gameMsg.Event, err)
+		return
+	}
 	switch gameMsg.Event {
 	case create:
 		go client.handleCreate()
 	case join:
-		go client.handleJoin(gameMsg)
+		go client.handleJoin(p.(*roomCodePayload))
+	case display:
+		go client.handleDisplay(p.(*roomCodePayload))
+	case lobby:
+		go client.handleLobby()
+	case quickMatch:
+		go client.handleQuickMatch()
+	case cancelMatch:
+		go client.leaveQuickMatch()
 	case setUsername:
-		go client.handleUsername(gameMsg)
+		go client.handleUsername(p.(*usernamePayload))
+	case updateSettings:
+		go client.handleSettings(p.(*settingsPayload))
 	case ready:
 		go client.handleReady()
+	case question:
+		go client.handleQuestion(p.(*questionPayload))
+	case questionVote:
+		go client.handleQuestionVote(p.(*choicePayload))
 	case prompt:
-		go client.handlePrompt(gameMsg)
+		go client.handlePrompt(p.(*promptPayload))
 	case pickPicture:
-		go client.handlePicture(gameMsg)
+		go client.handlePicture(p.(*picturePayload))
+	case guess:
+		go client.handleGuess(p.(*promptPayload))
+	case teamVote:
+		go client.handleTeamVote(p.(*choicePayload))
 	case vote:
-		go client.handleVote(gameMsg)
+		go client.handleVote(p.(*ballotPayload))
+	case audienceVote:
+		go client.handleAudienceVote(p.(*choicePayload))
+	case chat:
+		go client.handleChat(p.(*chatPayload))
+	case react:
+		go client.handleReaction(p.(*reactionPayload))
 	case leave:
 		go client.handleLeave()
 	case CloseWS:
@@ -143,6 +284,34 @@ func DispatchGameEvent(client *Client, gameMsg *GameMessage) {
 	}
 }
 
+// Decodes a message the client sent and dispatches it. Messages that can't be
+// decoded are rejected back to the client, which stays connected. Messages sent for
+// a protocol version the server doesn't speak get an unknown-version event instead.
+func ReceiveMessage(client *Client, msg []byte) {
+	gameMsg := &GameMessage{}
+	err := json.Unmarshal(msg, gameMsg)
+	if errors.Is(err, errUnsupportedVersion) {
+		log.Printf("Error decoding message from %s: %v", client.UserID, err)
+		go client.sendUnknownVersion(err)
+		return
+	}
+	if err != nil {
+		log.Printf("Error decoding message from %s: %v", client.UserID, err)
+		go client.sendRejection(gameMsg.Event, err)
+		return
+	}
+	DispatchGameEvent(client, gameMsg)
+}
+
+// Tells the client that one of its messages was rejected and why.
+func (c *Client) sendRejection(event gameEvent, reason error) {
+	rd := &rejectionData{Event: event, Message: reason.Error()}
+	err := sendPage(c, rejected, rd, generateRejection)
+	if err != nil {
+		log.Printf("Error creating rejection template: %v", err)
+	}
+}
+
 // Continually reads in all incoming messages from the pub/sub communication line.
 // Dispatches the appropriate event handler for the incoming internal event.
 func (c *Client) readPump() {
@@ -161,13 +330,45 @@ func (c *Client) readPump() {
 
 			switch psEvent.Event {
 			case newPlayerList:
-				go c.updatePlayerList([]byte(psEvent.Msg))
+				go c.updatePlayerList(psEvent.Msg)
+			case newSettings:
+				go c.updateSettingsPanel(psEvent.Msg)
+			case askPage, questionVotePage:
+				go c.displayQuestionPage(psEvent.Event, psEvent.Msg)
 			case enterGame:
-				go c.loadGame([]byte(psEvent.Msg))
+				go c.loadGame(psEvent.Msg)
+			case telephonePage:
+				go c.displayTelephonePage(psEvent.Msg)
+			case chainReveal:
+				go c.displayChainReveal(psEvent.Msg)
+			case guessPage:
+				go c.displayGuessingPage(psEvent.Msg)
+			case huddlePage:
+				go c.displayHuddlePage(psEvent.Msg)
 			case votePage:
-				go c.displayCandidates([]byte(psEvent.Msg))
+				go c.displayCandidates(psEvent.Msg)
 			case sendLeaderboard:
-				go c.displayLeaderboard([]byte(psEvent.Msg))
+				go c.displayLeaderboard(psEvent.Msg)
+			case newChatMessage:
+				go c.displayChatMessage(psEvent.Msg)
+			case newReaction:
+				go c.displayReaction(psEvent.Msg)
+			case roomClosed:
+				go c.handleRoomClosed()
+			case notice:
+				if psEvent.Recipient == c.UserID {
+					go c.sendNotice(psEvent.Msg)
+				}
+			case queued:
+				if psEvent.Recipient == c.UserID {
+					c.setQueued(true)
+					go c.waitForNextRound()
+				}
+			case promoted:
+				if psEvent.Recipient == c.UserID {
+					c.setQueued(false)
+					go c.joinGame()
+				}
 			}
 		case <-c.Ctx.Done():
 			return
@@ -196,6 +397,18 @@ func (c *Client) backupClientData() error {
 	if err != nil {
 		return errors.New("Error backing up username")
 	}
+	err = setRedisHash(c.Ctx, c.UserID, string(spectating), c.Spectator)
+	if err != nil {
+		return errors.New("Error backing up spectator status")
+	}
+	err = setRedisHash(c.Ctx, c.UserID, string(queuedStatus), c.Queued)
+	if err != nil {
+		return errors.New("Error backing up queued status")
+	}
+	err = setRedisHash(c.Ctx, c.UserID, string(displaying), c.Display)
+	if err != nil {
+		return errors.New("Error backing up display status")
+	}
 	return nil
 }
 
@@ -206,7 +419,7 @@ func (c *Client) deleteClientBackup() error {
 
 // Creates a new room and sends the user to the username page.
 func (c *Client) handleCreate() {
-	room, err := createRoom()
+	room, err := createRoom(c.UserID)
 	if err != nil {
 		log.Printf("Error creating new room: %f", err)
 		return
@@ -215,39 +428,69 @@ func (c *Client) handleCreate() {
 	if err != nil {
 		log.Printf("Error joining room: %v", err)
 	}
-	usernamePage, err := generateUsername()
+	c.sendUsernamePage()
+}
+
+// Connects the user to the room with the given code (if it exists) and sends the user to
+// the username page.
+func (c *Client) handleJoin(p *roomCodePayload) {
+	roomID, exists, err := roomRepo.resolveCode(c.Ctx, p.Code)
 	if err != nil {
-		log.Printf("Error creating username page template: %v", err)
+		log.Printf("Error looking up room code: %v", err)
+		return
+	}
+	if !exists {
+		c.sendNotice("There is no room with that code")
 		return
 	}
-	c.WriteChan <- usernamePage
+	err = c.joinRoom(roomID)
+	if err != nil {
+		log.Printf("Error joining room: %v", err)
+	}
+	c.sendUsernamePage()
 }
 
-// Connects the user to the specified room (if it exists) and sends the user to
-// the username page.
-func (c *Client) handleJoin(gameMsg *GameMessage) {
-	roomID := gameMsg.Msg
-	err := c.joinRoom(roomID)
+// Sends the user to the username page of the room they joined.
+func (c *Client) sendUsernamePage() {
+	code, err := c.fetchRoomCode()
 	if err != nil {
-		log.Printf("Error joining room: %v", err)
+		log.Printf("Error fetching room code: %v", err)
 	}
-	usernamePage, err := generateUsername()
+	err = sendPage(c, usernamePage, &usernamePageData{RoomCode: code}, generateUsername)
 	if err != nil {
 		log.Printf("Error creating username page template: %v", err)
-		return
 	}
-	c.WriteChan <- usernamePage
 }
 
 // Accepts the player's chosen username and sends the user to the waiting room.
-func (c *Client) handleUsername(gameMsg *GameMessage) {
+// Users who chose to spectate are sent to the page the room is on instead.
+// The room replaces the waiting room with the current page if the game has already started.
+func (c *Client) handleUsername(p *usernamePayload) {
 	c.Mutex.Lock()
-	c.Username = gameMsg.Msg
+	c.Username = p.Username
+	c.Spectator = p.Spectate
 	c.Mutex.Unlock()
+	if c.Spectator {
+		c.startSpectating()
+		c.sendChatPanel()
+		return
+	}
 	err := setRedisHash(c.Ctx, c.UserID, string(ready), false)
 	if err != nil {
 		log.Printf("Error initializing player status: %v", err)
 	}
+	code, err := c.fetchRoomCode()
+	if err != nil {
+		log.Printf("Error fetching room code: %v", err)
+	}
+	wpd := &waitingPageData{RoomCode: code, Compact: c.hasDisplay()}
+	err = sendPage(c, waitingPage, wpd, generateWaitingPage)
+	if err != nil {
+		log.Printf("Error creating waiting page template: %v", err)
+		return
+	}
+	c.sendSettingsPanel()
+	c.sendChatPanel()
 	newUserMsg, err := json.Marshal(
 		newPSMessage(newUser, c.UserID, c.Username),
 	)
@@ -258,15 +501,136 @@ func (c *Client) handleUsername(gameMsg *GameMessage) {
 	err = publishClientMessage(c, newUserMsg)
 	if err != nil {
 		log.Printf("Error publishing new username: %v", err)
+	}
+}
+
+// Relays the host's settings form to the room.
+func (c *Client) handleSettings(p *settingsPayload) {
+	fields, err := json.Marshal(p.Fields)
+	if err != nil {
+		log.Printf("Error encoding room settings: %v", err)
 		return
 	}
-	wpd := &waitingPageData{RoomID: c.RoomID}
-	waitingPage, err := generateWaitingPage(wpd)
+	settingsMsg, err := json.Marshal(newPSMessage(updateSettings, c.UserID, string(fields)))
 	if err != nil {
-		log.Printf("Error creating waiting page template: %v", err)
+		log.Printf("Error encoding room settings message: %v", err)
+		return
+	}
+	err = publishClientMessage(c, settingsMsg)
+	if err != nil {
+		log.Printf("Error publishing room settings: %v", err)
+	}
+}
+
+// Sends the room settings panel using the settings backed up in the database.
+func (c *Client) sendSettingsPanel() {
+	settings, err := getRedisHash(c.Ctx, c.RoomID, string(settingsBackup))
+	if err != nil {
+		log.Printf("Error fetching room settings: %v", err)
+		return
+	}
+	c.updateSettingsPanel(settings)
+}
+
+// Sends the room settings panel. Only the host is sent the settings form.
+func (c *Client) updateSettingsPanel(msg string) {
+	sm, err := parseSettingsMessage(msg)
+	if err != nil {
+		log.Printf("Error parsing room settings: %v", err)
 		return
 	}
-	c.WriteChan <- waitingPage
+	selected := make(map[string]bool, len(sm.Settings.ScoringRules))
+	for _, name := range sm.Settings.ScoringRules {
+		selected[name] = true
+	}
+	spd := &settingsPanelData{
+		IsHost:        sm.Host == c.UserID,
+		RoomName:      sm.Settings.Name,
+		MaxNameLength: maxRoomNameLength,
+	}
+	for _, public := range []bool{false, true} {
+		value := "private"
+		if public {
+			value = "public"
+		}
+		spd.Visibilities = append(spd.Visibilities, settingOption{
+			Value:    value,
+			Label:    visibilityDescription(public),
+			Selected: public == sm.Settings.Public,
+		})
+	}
+	for _, mode := range gameModes {
+		spd.GameModes = append(spd.GameModes, settingOption{
+			Value:    string(mode),
+			Label:    mode.description(),
+			Selected: mode == sm.Settings.GameMode,
+		})
+	}
+	for _, source := range questionSources {
+		spd.QuestionSources = append(spd.QuestionSources, settingOption{
+			Value:    string(source),
+			Label:    source.description(),
+			Selected: source == sm.Settings.Questions,
+		})
+	}
+	for _, deck := range constraintDecks {
+		spd.ConstraintDecks = append(spd.ConstraintDecks, settingOption{
+			Value:    deck.Name,
+			Label:    deck.Description,
+			Selected: deck.Name == sm.Settings.ConstraintDeck,
+		})
+	}
+	for _, schedule := range editSchedules {
+		spd.EditSchedules = append(spd.EditSchedules, settingOption{
+			Value:    string(schedule),
+			Label:    schedule.description(),
+			Selected: schedule == sm.Settings.EditRounds,
+		})
+	}
+	for _, rule := range scoringRules.all() {
+		spd.ScoringRules = append(spd.ScoringRules, settingOption{
+			Value:    rule.Name(),
+			Label:    rule.Description(),
+			Selected: selected[rule.Name()],
+		})
+	}
+	for _, mode := range votingModes {
+		spd.VotingModes = append(spd.VotingModes, settingOption{
+			Value:    string(mode),
+			Label:    mode.description(),
+			Selected: mode == sm.Settings.VotingMode,
+		})
+	}
+	spd.TeamCounts = append(spd.TeamCounts, settingOption{
+		Value:    "0",
+		Label:    "No teams",
+		Selected: sm.Settings.Teams == 0,
+	})
+	for count := 2; count <= len(teamNames); count++ {
+		spd.TeamCounts = append(spd.TeamCounts, settingOption{
+			Value:    strconv.Itoa(count),
+			Label:    fmt.Sprintf("%d teams", count),
+			Selected: sm.Settings.Teams == count,
+		})
+	}
+	for _, submission := range teamSubmissions {
+		spd.TeamSubmissions = append(spd.TeamSubmissions, settingOption{
+			Value:    string(submission),
+			Label:    submission.description(),
+			Selected: submission == sm.Settings.TeamSubmission,
+		})
+	}
+	for _, bonus := range audienceBonuses {
+		spd.AudienceBonuses = append(spd.AudienceBonuses, settingOption{
+			Value:    strconv.Itoa(bonus),
+			Label:    audienceBonusDescription(bonus),
+			Selected: bonus == sm.Settings.AudienceBonus,
+		})
+	}
+	err = sendPage(c, newSettings, spd, generateSettingsPanel)
+	if err != nil {
+		log.Printf("Error creating room settings template: %v", err)
+	}
 }
 
 // Marks the player as ready to start the next round.
@@ -276,7 +640,7 @@ func (c *Client) handleReady() {
 		log.Printf("Error setting player status to ready: %v", err)
 		return
 	}
-	readyMsg, err := json.Marshal(newPSMessage(ready, c.UserID, c.UserID))
+	readyMsg, err := json.Marshal(newPSMessage(ready, c.UserID, ""))
 	if err != nil {
 		log.Printf("Error encoding new user message: %v", err)
 		return
@@ -288,13 +652,69 @@ func (c *Client) handleReady() {
 	}
 }
 
+// Shows a notice, such as a rejected input, above the current page.
+func (c *Client) sendNotice(message string) {
+	err := sendPage(c, notice, &noticeData{Message: message}, generateNotice)
+	if err != nil {
+		log.Printf("Error creating notice template: %v", err)
+	}
+}
+
+// Displays the question page, where players write and vote on questions.
+func (c *Client) displayQuestionPage(event gameEvent, questionPageData string) {
+	err := c.backupClientData()
+	if err != nil {
+		log.Println(err)
+	}
+	err = c.unreadyPlayer()
+	if err != nil {
+		log.Printf("Error setting player status to unready: %v", err)
+		return
+	}
+	err = c.sendView(event, questionPageData)
+	if err != nil {
+		log.Printf("Error creating question page template: %v", err)
+	}
+}
+
+// Relays the player's question for the next round to the room.
+func (c *Client) handleQuestion(p *questionPayload) {
+	c.relayReadyMessage(question, p.Question)
+}
+
+// Relays the player's vote for the question of the next round to the room.
+func (c *Client) handleQuestionVote(p *choicePayload) {
+	c.relayReadyMessage(questionVote, p.CandidateID)
+}
+
+// Marks the player as ready and relays their input to the room.
+func (c *Client) relayReadyMessage(event gameEvent, msg string) {
+	err := c.readyPlayer()
+	if err != nil {
+		log.Printf("Error setting player status to ready: %v", err)
+		return
+	}
+	readyMsg, err := json.Marshal(newPSMessage(event, c.UserID, msg))
+	if err != nil {
+		log.Printf("Error encoding %s message: %v", event, err)
+		return
+	}
+	err = publishClientMessage(c, readyMsg)
+	if err != nil {
+		log.Printf("Error publishing %s message: %v", event, err)
+	}
+}
+
 // Sends the updated player list after a new user joins.
-func (c *Client) updatePlayerList(players []byte) {
-	c.WriteChan <- players
+func (c *Client) updatePlayerList(players string) {
+	err := c.sendView(newPlayerList, players)
+	if err != nil {
+		log.Printf("Error creating player list template: %v", err)
+	}
 }
 
 // Sends the user to the game page. Called after all players have been marked as ready.
-func (c *Client) loadGame(gamePage []byte) {
+func (c *Client) loadGame(gamePageData string) {
 	err := c.backupClientData()
 	if err != nil {
 		log.Println(err)
@@ -304,7 +724,10 @@ func (c *Client) loadGame(gamePage []byte) {
 		log.Printf("Error setting player status to unready: %v", err)
 		return
 	}
-	c.WriteChan <- gamePage
+	err = c.sendView(enterGame, gamePageData)
+	if err != nil {
+		log.Printf("Error creating game page template: %v", err)
+	}
 }
 
 // Handles a user submitted exit signal.
@@ -318,12 +741,13 @@ func (c *Client) handleLeave() {
 }
 
 // Handles an unexpected WebSocket disconnection by halting all goroutines associated
-// with the client.
+// with the client. WriteChan is left open, since handlers may still be writing to it;
+// writers and readers stop once the client's context is done.
 func (c *Client) handleClose() {
 	defer c.Cancel()
-	defer close(c.WriteChan)
 
-	closeMsg, err := json.Marshal(newPSMessage(CloseWS, c.UserID, c.UserID))
+	c.leaveQuickMatch()
+	closeMsg, err := json.Marshal(newPSMessage(CloseWS, c.UserID, ""))
 	if err != nil {
 		log.Printf("Error encoding close message: %v", err)
 	}
@@ -335,35 +759,74 @@ func (c *Client) handleClose() {
 }
 
 // Handles the user submitted prompt by generating a picture and sending it back.
+// Prompts that break the round's constraint are rejected before generation.
+// In edit rounds the prompt is an instruction for editing the previous winner's picture.
 // Note that prompts that OpenAI content violations will not generate a picture.
-func (c *Client) handlePrompt(gameMsg *GameMessage) {
-	url, err := generateAIPicture(c.Ctx, gameMsg.Msg)
+func (c *Client) handlePrompt(p *promptPayload) {
+	con, err := c.fetchConstraint()
+	if err != nil {
+		log.Printf("Error fetching round constraint: %v", err)
+		return
+	}
+	generationPrompt := p.Prompt
+	if con != nil {
+		err = con.check(p.Prompt)
+		if err != nil {
+			c.sendNotice(err.Error())
+			return
+		}
+		generationPrompt = con.apply(p.Prompt)
+	}
+	baseURL, err := c.fetchBasePicture()
+	if err != nil {
+		log.Printf("Error fetching base picture: %v", err)
+		return
+	}
+	var url string
+	if baseURL != "" {
+		url, err = images.edit(c.Ctx, baseURL, generationPrompt)
+	} else {
+		url, err = images.generate(c.Ctx, generationPrompt)
+	}
 	if err != nil {
 		log.Printf("Error generating image: %v", err)
 		return
 	}
+	err = setRedisHash(c.Ctx, c.UserID, promptField(url), p.Prompt)
+	if err != nil {
+		log.Printf("Error storing user prompt: %v", err)
+		return
+	}
 	ipd := &imagePreviewData{URL: url}
-	picturePreview, err := generatePicturePreview(ipd)
+	err = sendPage(c, picturePreview, ipd, generatePicturePreview)
 	if err != nil {
 		log.Printf("Error creating picture preview template: %v", err)
-		return
 	}
-	c.WriteChan <- picturePreview
 }
 
 // Accepts the user's chosen picture. Stores in database and relays to the room.
-func (c *Client) handlePicture(gameMsg *GameMessage) {
+func (c *Client) handlePicture(p *picturePayload) {
 	err := c.readyPlayer()
 	if err != nil {
 		log.Printf("Error setting player status to ready: %v", err)
 		return
 	}
-	err = setRedisHash(c.Ctx, c.UserID, string(picture), gameMsg.Msg)
+	err = setRedisHash(c.Ctx, c.UserID, string(picture), p.URL)
 	if err != nil {
 		log.Printf("Error storing user prompt: %v", err)
 		return
 	}
-	sentPrompt, err := json.Marshal(newPSMessage(getPicture, c.UserID, gameMsg.Msg))
+	userPrompt, err := getRedisHash(c.Ctx, c.UserID, promptField(p.URL))
+	if err != nil {
+		log.Printf("Error fetching prompt for picture: %v", err)
+		return
+	}
+	submission, err := json.Marshal(&submissionPayload{URL: p.URL, Prompt: userPrompt})
+	if err != nil {
+		log.Printf("Error encoding submitted picture: %v", err)
+		return
+	}
+	sentPrompt, err := json.Marshal(newPSMessage(getPicture, c.UserID, string(submission)))
 	if err != nil {
 		log.Printf("Error encoding user prompt: %v", err)
 		return
@@ -375,29 +838,136 @@ func (c *Client) handlePicture(gameMsg *GameMessage) {
 	}
 }
 
+// Gets the field of the user's backup that holds the prompt for the picture at url.
+func promptField(url string) string {
+	return fmt.Sprintf("%s:%s", promptText, url)
+}
+
+// Displays the chain the player should continue in telephone mode.
+// The page is only re-rendered when the player's assignment changes, so that
+// a player's prompt isn't cleared whenever another player passes a chain on.
+func (c *Client) displayTelephonePage(pageData string) {
+	tpd := &telephonePageData{}
+	err := json.Unmarshal([]byte(pageData), tpd)
+	if err != nil {
+		log.Printf("Error parsing telephone page: %v", err)
+		return
+	}
+	key := tpd.Assignments[c.UserID].key(tpd.Round)
+	c.Mutex.Lock()
+	unchanged := c.Assignment == key
+	c.Assignment = key
+	c.Mutex.Unlock()
+	if unchanged {
+		return
+	}
+
+	err = c.backupClientData()
+	if err != nil {
+		log.Println(err)
+	}
+	err = c.sendView(telephonePage, pageData)
+	if err != nil {
+		log.Printf("Error creating telephone page template: %v", err)
+	}
+}
+
+// Displays every finished chain at the end of a telephone round.
+// Also shows the room settings, which the host may change between rounds.
+func (c *Client) displayChainReveal(chainRevealData string) {
+	err := c.unreadyPlayer()
+	if err != nil {
+		log.Printf("Error setting player status to unready: %v", err)
+		return
+	}
+	err = c.sendView(chainReveal, chainRevealData)
+	if err != nil {
+		log.Printf("Error creating chain reveal template: %v", err)
+		return
+	}
+	c.sendSettingsPanel()
+}
+
+// Displays the artist's picture so the player can guess its prompt.
+func (c *Client) displayGuessingPage(guessingPageData string) {
+	err := c.unreadyPlayer()
+	if err != nil {
+		log.Printf("Error setting player status to unready: %v", err)
+		return
+	}
+	err = c.sendView(guessPage, guessingPageData)
+	if err != nil {
+		log.Printf("Error creating guessing page template: %v", err)
+	}
+}
+
+// Relays the player's guess at the real prompt to the room.
+func (c *Client) handleGuess(p *promptPayload) {
+	err := c.readyPlayer()
+	if err != nil {
+		log.Printf("Error setting player status to ready: %v", err)
+		return
+	}
+	guessMsg, err := json.Marshal(newPSMessage(guess, c.UserID, p.Prompt))
+	if err != nil {
+		log.Printf("Error encoding guess: %v", err)
+		return
+	}
+	err = publishClientMessage(c, guessMsg)
+	if err != nil {
+		log.Printf("Error publishing guess: %v", err)
+	}
+}
+
+// Displays the pictures painted by the player's team so the team can pick one.
+func (c *Client) displayHuddlePage(huddlePageData string) {
+	err := c.unreadyPlayer()
+	if err != nil {
+		log.Printf("Error setting player status to unready: %v", err)
+		return
+	}
+	err = c.sendView(huddlePage, huddlePageData)
+	if err != nil {
+		log.Printf("Error creating huddle page template: %v", err)
+	}
+}
+
+// Relays the player's vote for the picture their team submits to the room.
+func (c *Client) handleTeamVote(p *choicePayload) {
+	c.relayReadyMessage(teamVote, p.CandidateID)
+}
+
 // Displays all of the client submissions for the room.
-func (c *Client) displayCandidates(candidates []byte) {
+func (c *Client) displayCandidates(votingPageData string) {
 	err := c.unreadyPlayer()
 	if err != nil {
 		log.Printf("Error setting player status to unready: %v", err)
 		return
 	}
-	c.WriteChan <- candidates
+	err = c.sendView(votePage, votingPageData)
+	if err != nil {
+		log.Printf("Error creating voting page template: %v", err)
+	}
 }
 
-// Handles the players vote by updating the database and relaying to the room.
-func (c *Client) handleVote(gameMsg *GameMessage) {
+// Handles the player's vote by relaying the submitted ballot to the room.
+func (c *Client) handleVote(p *ballotPayload) {
 	err := c.readyPlayer()
 	if err != nil {
 		log.Printf("Error setting player status to ready: %v", err)
 		return
 	}
-	vote, err := json.Marshal(newPSMessage(gameMsg.Event, c.UserID, gameMsg.Msg))
+	ballot, err := json.Marshal(&p.Ballot)
+	if err != nil {
+		log.Printf("Error encoding ballot: %v", err)
+		return
+	}
+	voteMsg, err := json.Marshal(newPSMessage(vote, c.UserID, string(ballot)))
 	if err != nil {
 		log.Printf("Error encoding vote: %v", err)
 		return
 	}
-	err = publishClientMessage(c, vote)
+	err = publishClientMessage(c, voteMsg)
 	if err != nil {
 		log.Printf("Error publishing player vote: %v", err)
 		return
@@ -405,11 +975,17 @@ func (c *Client) handleVote(gameMsg *GameMessage) {
 }
 
 // Displays the current leaderboard, which includes round scores and total scores.
-func (c *Client) displayLeaderboard(leaderboard []byte) {
+// Also shows the room settings, which the host may change between rounds.
+func (c *Client) displayLeaderboard(leaderboardPageData string) {
 	err := c.unreadyPlayer()
 	if err != nil {
 		log.Printf("Error setting player status to unready: %v", err)
 		return
 	}
-	c.WriteChan <- leaderboard
+	err = c.sendView(sendLeaderboard, leaderboardPageData)
+	if err != nil {
+		log.Printf("Error creating leaderboard page template: %v", err)
+		return
+	}
+	c.sendSettingsPanel()
 }
@@ -0,0 +1,152 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Room codes alternate consonants and vowels so they can be read out loud.
+// Letters that are easily misheard or confused with digits are left out.
+const (
+	codeConsonants = "BDFGHJKLMNPRSTVWZ"
+	codeVowels     = "AEIOU"
+)
+
+const (
+	minCodeLength = 4  // The length tried first for new room codes
+	maxCodeLength = 6  // The longest room code, used when shorter codes keep colliding
+	codeAttempts  = 20 // The number of codes tried at each length
+)
+
+// How long the code of a deleted room stays reserved, so that players holding
+// an old code don't end up in a stranger's room.
+const codeCooldown = 10 * time.Minute
+
+// Makes a random pronounceable room code of the given length.
+func makeRoomCode(length int) string {
+	code := make([]byte, length)
+	for i := range code {
+		letters := codeConsonants
+		if i%2 == 1 {
+			letters = codeVowels
+		}
+		code[i] = letters[rand.Intn(len(letters))]
+	}
+	return string(code)
+}
+
+// Reports whether the text is shaped like a room code, ignoring case.
+// Does not check whether a room uses the code.
+func IsRoomCode(text string) bool {
+	code := normalizeRoomCode(text)
+	if len(code) < minCodeLength || len(code) > maxCodeLength {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		letters := codeConsonants
+		if i%2 == 1 {
+			letters = codeVowels
+		}
+		if !strings.ContainsRune(letters, rune(code[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// Normalizes a room code typed by a player, since codes are case-insensitive.
+func normalizeRoomCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Gets the database key that maps a room code to its room.
+func (g *roomRepository) codeKey(code string) string {
+	return fmt.Sprintf("%s:%s", g.roomCodes, code)
+}
+
+// Reserves a new room code for the room. Codes that are in use, cooling down or
+// offensive are skipped, and longer codes are tried when short codes keep colliding.
+func (g *roomRepository) reserveCode(ctx context.Context, roomID string) (string, error) {
+	for length := minCodeLength; length <= maxCodeLength; length++ {
+		for attempt := 0; attempt < codeAttempts; attempt++ {
+			code := makeRoomCode(length)
+			if containsProfanity(code) {
+				continue
+			}
+			reserved, err := reserveRedisKey(ctx, g.codeKey(code), roomID, expireTime)
+			if err != nil {
+				return "", err
+			}
+			if reserved {
+				return code, nil
+			}
+		}
+	}
+	return "", errors.New("Unable to find a free room code")
+}
+
+// Keeps the room's code reserved. Codes expire once their room has been idle for an hour.
+// Returns false if the code expired and was given to another room.
+func (g *roomRepository) renewCode(ctx context.Context, code, roomID string) (bool, error) {
+	renewed, err := compareAndSetRedisKey(ctx, g.codeKey(code), roomID, roomID, expireTime)
+	if err != nil || renewed {
+		return renewed, err
+	}
+	return reserveRedisKey(ctx, g.codeKey(code), roomID, expireTime)
+}
+
+// Releases the code of a deleted room. The code can be reused once it has cooled down.
+// Codes that were already given to another room are left alone.
+func (g *roomRepository) releaseCode(ctx context.Context, code, roomID string) error {
+	_, err := compareAndSetRedisKey(ctx, g.codeKey(code), roomID, "", codeCooldown)
+	return err
+}
+
+// Looks up the room a code belongs to, ignoring case.
+// Returns false if the code is unknown or its room no longer exists.
+func (g *roomRepository) resolveCode(ctx context.Context, code string) (string, bool, error) {
+	roomID, err := getRedisKey(ctx, g.codeKey(normalizeRoomCode(code)))
+	if err == redis.Nil || roomID == "" {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+	exists, err := g.lookupRoom(ctx, roomID)
+	return roomID, exists, err
+}
+
+// Keeps the room's code reserved. Rooms whose code was given to another room
+// while they sat idle are given a new code.
+func (r *Room) renewCode() error {
+	r.Mutex.RLock()
+	code := r.Code
+	r.Mutex.RUnlock()
+	renewed, err := roomRepo.renewCode(r.Ctx, code, r.ID)
+	if err != nil || renewed {
+		return err
+	}
+	code, err = roomRepo.reserveCode(r.Ctx, r.ID)
+	if err != nil {
+		return err
+	}
+	r.Mutex.Lock()
+	r.Code = code
+	r.Mutex.Unlock()
+	return r.backupCode()
+}
+
+// Backs up the room's code, so clients can show it.
+func (r *Room) backupCode() error {
+	return setRedisHash(r.Ctx, r.ID, string(roomCode), r.Code)
+}
+
+// Fetches the code of the client's room.
+func (c *Client) fetchRoomCode() (string, error) {
+	return getRedisHash(c.Ctx, c.RoomID, string(roomCode))
+}
@@ -0,0 +1,175 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"unicode"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Optional rules a round puts on the players' prompts.
+// Zero values mean the rule does not apply.
+type constraint struct {
+	Style        string   `json:"style,omitempty"`
+	WordLimit    int      `json:"wordLimit,omitempty"`
+	BannedWords  []string `json:"bannedWords,omitempty"`
+	RequiredWord string   `json:"requiredWord,omitempty"`
+}
+
+// Lists the rules of the constraint as shown to players.
+func (c *constraint) Rules() []string {
+	var rules []string
+	if c.Style != "" {
+		rules = append(rules, "Art style: "+c.Style)
+	}
+	if c.WordLimit > 0 {
+		rules = append(rules, fmt.Sprintf("Use at most %d words", c.WordLimit))
+	}
+	if len(c.BannedWords) > 0 {
+		rules = append(rules, "Don't use: "+strings.Join(c.BannedWords, ", "))
+	}
+	if c.RequiredWord != "" {
+		rules = append(rules, "Must include: "+c.RequiredWord)
+	}
+	return rules
+}
+
+// Splits a prompt into lowercase words without surrounding punctuation.
+func promptWords(prompt string) []string {
+	words := strings.Fields(strings.ToLower(prompt))
+	for i, word := range words {
+		words[i] = strings.TrimFunc(word, unicode.IsPunct)
+	}
+	return words
+}
+
+// Checks that a prompt follows the constraint.
+func (c *constraint) check(prompt string) error {
+	words := promptWords(prompt)
+	if c.WordLimit > 0 && len(words) > c.WordLimit {
+		return fmt.Errorf("Your prompt can be at most %d words", c.WordLimit)
+	}
+	used := make(map[string]bool, len(words))
+	for _, word := range words {
+		used[word] = true
+	}
+	for _, banned := range c.BannedWords {
+		if used[strings.ToLower(banned)] {
+			return fmt.Errorf("You can't use the word \"%s\" this round", banned)
+		}
+	}
+	if c.RequiredWord != "" && !used[strings.ToLower(c.RequiredWord)] {
+		return fmt.Errorf("Your prompt must include the word \"%s\"", c.RequiredWord)
+	}
+	return nil
+}
+
+// Adds the forced art style to a prompt before it is sent for generation.
+func (c *constraint) apply(prompt string) string {
+	if c.Style == "" {
+		return prompt
+	}
+	return fmt.Sprintf("%s, in the style of %s", prompt, c.Style)
+}
+
+// A named set of constraints the host can pick for the room.
+// Each round draws one constraint from the deck.
+type constraintDeck struct {
+	Name        string
+	Description string
+	Cards       []constraint
+}
+
+var (
+	styleCards = []constraint{
+		{Style: "watercolor"},
+		{Style: "pixel art"},
+		{Style: "oil painting"},
+		{Style: "comic book"},
+		{Style: "stained glass"},
+		{Style: "claymation"},
+	}
+	wordplayCards = []constraint{
+		{WordLimit: 5},
+		{WordLimit: 8},
+		{BannedWords: []string{"big", "small", "very"}},
+		{BannedWords: []string{"red", "blue", "green"}},
+		{RequiredWord: "banana"},
+		{RequiredWord: "robot"},
+		{RequiredWord: "moon"},
+	}
+	mixedCards = []constraint{
+		{Style: "pixel art", WordLimit: 6},
+		{Style: "watercolor", RequiredWord: "rain"},
+		{Style: "comic book", BannedWords: []string{"hero"}},
+		{WordLimit: 4, RequiredWord: "cat"},
+	}
+)
+
+// The constraint decks in the order they are offered in the room settings.
+var constraintDecks = []constraintDeck{
+	{Name: "none", Description: "No constraints"},
+	{Name: "styles", Description: "A forced art style each round", Cards: styleCards},
+	{Name: "wordplay", Description: "Word limits, banned words and required words", Cards: wordplayCards},
+	{
+		Name:        "mixed",
+		Description: "A bit of everything, sometimes combined",
+		Cards:       append(append(append([]constraint{}, styleCards...), wordplayCards...), mixedCards...),
+	},
+}
+
+// The deck every new room starts with.
+const defaultConstraintDeck = "none"
+
+// Looks up a constraint deck by name.
+func lookupConstraintDeck(name string) (constraintDeck, bool) {
+	for _, deck := range constraintDecks {
+		if deck.Name == name {
+			return deck, true
+		}
+	}
+	return constraintDeck{}, false
+}
+
+// Draws a constraint for the round. Returns nil if the deck has no constraints.
+func (d constraintDeck) draw() *constraint {
+	if len(d.Cards) == 0 {
+		return nil
+	}
+	c := d.Cards[rand.Intn(len(d.Cards))]
+	return &c
+}
+
+// Backs up the constraint of the current round, so clients can check prompts against it.
+// Clears the backup when the round has no constraint.
+func (r *Room) backupConstraint(c *constraint) error {
+	if c == nil {
+		return setRedisHash(r.Ctx, r.ID, string(constraintBackup), "")
+	}
+	constraintJSON, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return setRedisHash(r.Ctx, r.ID, string(constraintBackup), constraintJSON)
+}
+
+// Fetches the constraint of the room's current round.
+// Returns nil if the round has no constraint.
+func (c *Client) fetchConstraint() (*constraint, error) {
+	constraintJSON, err := getRedisHash(c.Ctx, c.RoomID, string(constraintBackup))
+	if err != nil && err != redis.Nil {
+		return nil, err
+	} else if constraintJSON == "" {
+		return nil, nil
+	}
+	con := &constraint{}
+	err = json.Unmarshal([]byte(constraintJSON), con)
+	if err != nil {
+		return nil, errors.New("Unable to parse round constraint")
+	}
+	return con, nil
+}
@@ -3,16 +3,25 @@ package game
 import (
 	"context"
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 // A data structure used to format all internal pub/sub messages.
+// Recipient is only set for messages meant for a single client.
 type PSMessage struct {
-	Event  gameEvent `json:"event"`
-	Sender string    `json:"sender"`
-	Msg    string    `json:"msg"`
+	Event     gameEvent `json:"event"`
+	Sender    string    `json:"sender"`
+	Msg       string    `json:"msg"`
+	Recipient string    `json:"recipient,omitempty"`
+}
+
+// A single member of a sorted set along with its score.
+type sortedSetMember struct {
+	Member string
+	Score  int
 }
 
 var rdb *redis.Client
@@ -65,6 +74,34 @@ func setRedisKey(ctx context.Context, key string, value any) error {
 	return rdb.Expire(ctx, key, expireTime).Err()
 }
 
+// Sets a key in database that expires after ttl, unless the key already exists.
+// Returns false if the key already exists.
+// Errors if database query errors.
+func reserveRedisKey(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	return rdb.SetNX(ctx, key, value, ttl).Result()
+}
+
+// Sets a key to a new value that expires after ttl, but only while it still holds
+// the expected value, so writers don't overwrite a key someone else has claimed.
+var compareAndSetScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// Sets a key in database that expires after ttl, if the key still holds expected.
+// Returns false if the key holds another value or does not exist.
+// Errors if database query errors.
+func compareAndSetRedisKey(ctx context.Context, key, expected, value string, ttl time.Duration) (bool, error) {
+	set, err := compareAndSetScript.Run(ctx, rdb, []string{key}, expected, value, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return set == 1, nil
+}
+
 // Gets the value associated with a key in database.
 // Errors if database query errors.
 func getRedisKey(ctx context.Context, key string) (string, error) {
@@ -77,6 +114,20 @@ func incrRedisKey(ctx context.Context, key string) error {
 	return rdb.Incr(ctx, key).Err()
 }
 
+// Increments a counter in the database. The counter is deleted ttl after it is created.
+// Returns the counter's new value.
+// Errors if the database query errors.
+func incrRedisCounter(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	count, err := rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		err = rdb.Expire(ctx, key, ttl).Err()
+	}
+	return count, err
+}
+
 // Deletes key from the database.
 // Errors if the database query errors.
 func deleteRedisKey(ctx context.Context, key string) error {
@@ -121,6 +172,124 @@ func getRedisHash(ctx context.Context, hash, key string) (string, error) {
 	return rdb.HGet(ctx, hash, key).Result()
 }
 
+// Gets every field and value of a hash in database.
+// Errors if database query errors.
+func getAllRedisHash(ctx context.Context, hash string) (map[string]string, error) {
+	return rdb.HGetAll(ctx, hash).Result()
+}
+
+// Deletes a field from a hash in database.
+// Errors if database query errors.
+func deleteFromRedisHash(ctx context.Context, hash, key string) error {
+	return rdb.HDel(ctx, hash, key).Err()
+}
+
+// Appends to a list in database. Creates the list if it does not yet exist.
+// Returns the length of the list after appending.
+// Errors if database query errors.
+func pushRedisList(ctx context.Context, key string, member any) (int64, error) {
+	length, err := rdb.RPush(ctx, key, member).Result()
+	if err != nil {
+		return 0, err
+	}
+	return length, rdb.Expire(ctx, key, expireTime).Err()
+}
+
+// Appends a member to a list unless it is already in it, then removes the first
+// size members once the list holds that many. Returns the list's length followed
+// by the removed members. Runs as one script, so that servers sharing the database
+// never split a group between them.
+var pushAndPopGroupScript = redis.NewScript(`
+if not redis.call("LPOS", KEYS[1], ARGV[1]) then
+	redis.call("RPUSH", KEYS[1], ARGV[1])
+	redis.call("EXPIRE", KEYS[1], ARGV[3])
+end
+local size = tonumber(ARGV[2])
+if redis.call("LLEN", KEYS[1]) < size then
+	return {redis.call("LLEN", KEYS[1])}
+end
+local group = redis.call("LPOP", KEYS[1], size)
+local result = {redis.call("LLEN", KEYS[1])}
+for _, member in ipairs(group) do
+	table.insert(result, member)
+end
+return result
+`)
+
+// Appends a member to a list in database unless it is already in it. Once the list
+// holds size members, removes and returns the first size of them.
+// Returns the removed members, if any, and the length of the list afterwards.
+// Errors if database query errors.
+func pushAndPopRedisListGroup(ctx context.Context, key, member string, size int) ([]string, int64, error) {
+	result, err := pushAndPopGroupScript.Run(ctx, rdb, []string{key}, member, size, int(expireTime.Seconds())).Slice()
+	if err != nil {
+		return nil, 0, err
+	}
+	length, _ := result[0].(int64)
+	group := make([]string, 0, len(result)-1)
+	for _, member := range result[1:] {
+		if memberString, ok := member.(string); ok {
+			group = append(group, memberString)
+		}
+	}
+	return group, length, nil
+}
+
+// Puts members back at the front of a list in database, keeping their order.
+// Errors if database query errors.
+func unpopRedisList(ctx context.Context, key string, members []string) error {
+	reversed := make([]any, 0, len(members))
+	for i := len(members) - 1; i >= 0; i-- {
+		reversed = append(reversed, members[i])
+	}
+	return rdb.LPush(ctx, key, reversed...).Err()
+}
+
+// Gets the length of a list in database.
+// Errors if database query errors.
+func lengthRedisList(ctx context.Context, key string) (int64, error) {
+	return rdb.LLen(ctx, key).Result()
+}
+
+// Prepends to a list in database and trims the list to its newest length members.
+// Errors if database query errors.
+func pushCappedRedisList(ctx context.Context, key string, member any, length int64) error {
+	err := rdb.LPush(ctx, key, member).Err()
+	if err != nil {
+		return err
+	}
+	return rdb.LTrim(ctx, key, 0, length-1).Err()
+}
+
+// Gets every member of a list in database, from front to back.
+// Errors if database query errors.
+func getRedisList(ctx context.Context, key string) ([]string, error) {
+	return rdb.LRange(ctx, key, 0, -1).Result()
+}
+
+// Deletes every occurrence of member from a list in database.
+// Errors if database query errors.
+func deleteFromRedisList(ctx context.Context, key string, member any) error {
+	return rdb.LRem(ctx, key, 0, member).Err()
+}
+
+// Subscribes to a pub/sub channel and waits until the subscription is active,
+// so that no message published afterwards is missed.
+func subscribeChannel(ctx context.Context, channel string) (*redis.PubSub, error) {
+	pubsub := rdb.Subscribe(ctx, channel)
+	_, err := pubsub.Receive(ctx)
+	if err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	return pubsub, nil
+}
+
+// Publishes a message to a pub/sub channel.
+func publishChannelMessage(ctx context.Context, channel string, msg []byte) error {
+	return rdb.Publish(ctx, channel, msg).Err()
+}
+
 // Adds to a sorted set in the database. Creates the set if it does not exist.
 // Errors if the database query errors.
 func addToRedisSortedSet(ctx context.Context, key, member string) error {
@@ -148,26 +317,52 @@ func updateRedisSortedSet(ctx context.Context, key, member string, score int) er
 	return rdb.ZIncrBy(ctx, key, float64(score), member).Err()
 }
 
-// Retrieves a sorted set with the scores as a map from members to scores.
+// Retrieves a sorted set with the scores, ordered from highest to lowest score.
 // Errors if the database query errors.
-func getRedisSortedSetWithScores(ctx context.Context, key string) (map[string]int, error) {
+func getRedisSortedSetWithScores(ctx context.Context, key string) ([]sortedSetMember, error) {
 	setSlice, err := rdb.ZRevRangeWithScores(ctx, key, 0, -1).Result()
 	if err != nil {
 		return nil, err
 	}
 
-	set := make(map[string]int, len(setSlice))
+	set := make([]sortedSetMember, 0, len(setSlice))
 	for _, z := range setSlice {
 		member, ok := z.Member.(string)
 		if !ok {
-			log.Printf("Error fetching member from sorted set: %v", err)
+			log.Printf("Error fetching member from sorted set: %v", z.Member)
 			continue
 		}
-		set[member] = int(z.Score)
+		set = append(set, sortedSetMember{Member: member, Score: int(z.Score)})
 	}
 	return set, nil
 }
 
+// Adds a member to a sorted set with the given score, or updates the member's score.
+// Unlike addToRedisSortedSet, the set does not expire.
+// Errors if the database query errors.
+func scheduleRedisSortedSet(ctx context.Context, key, member string, score float64) error {
+	return rdb.ZAdd(ctx, key, redis.Z{Score: score, Member: member}).Err()
+}
+
+// Retrieves up to count members of a sorted set with a score of at most max,
+// ordered from lowest to highest score.
+// Errors if the database query errors.
+func getRedisSortedSetUpTo(ctx context.Context, key string, max float64, count int64) ([]string, error) {
+	return rdb.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatFloat(max, 'f', -1, 64),
+		Count: count,
+	}).Result()
+}
+
+// Removes member from a sorted set. Returns false if the member was already gone,
+// so that only one server claims each member.
+// Errors if the database query errors.
+func claimRedisSortedSetMember(ctx context.Context, key, member string) (bool, error) {
+	removed, err := rdb.ZRem(ctx, key, member).Result()
+	return removed == 1, err
+}
+
 // Deletes member from a sorted set.
 // Errors if the database query errors.
 func deleteFromRedisSortedSet(ctx context.Context, key, member string) error {
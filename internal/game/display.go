@@ -0,0 +1,136 @@
+package game
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+)
+
+// How many seconds the display counts down while players paint.
+// The countdown only paces the room, the round still waits for every player.
+const displayCountdown = 90
+
+// Adds a display to the room, which shows the game on a shared screen without playing.
+// Also used when a display reconnects.
+func (r *Room) addDisplay(userID string) {
+	r.Mutex.Lock()
+	r.Displays[userID] = true
+	count := len(r.Displays)
+	r.Mutex.Unlock()
+
+	err := r.backupDisplayCount(count)
+	if err != nil {
+		log.Printf("Error backing up display count: %v", err)
+	}
+	err = r.publishPlayerList()
+	if err != nil {
+		log.Printf("Error publishing new player list: %v", err)
+	}
+}
+
+// Removes a display from the room. Returns false if the user was not a display.
+func (r *Room) removeDisplay(userID string) bool {
+	r.Mutex.Lock()
+	if !r.Displays[userID] {
+		r.Mutex.Unlock()
+		return false
+	}
+	delete(r.Displays, userID)
+	count := len(r.Displays)
+	r.Mutex.Unlock()
+
+	err := r.backupDisplayCount(count)
+	if err != nil {
+		log.Printf("Error backing up display count: %v", err)
+	}
+	return true
+}
+
+// Backs up the number of displays showing the room, so players' clients can
+// switch to their compact controller views.
+func (r *Room) backupDisplayCount(count int) error {
+	return setRedisHash(r.Ctx, r.ID, string(displayCount), count)
+}
+
+// Reports whether the client is a display rather than a player.
+func (c *Client) isDisplay() bool {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	return c.Display
+}
+
+// Reports whether a display is showing the client's room.
+// Views already on screen keep their layout until the room moves to the next page.
+func (c *Client) hasDisplay() bool {
+	count, err := getRedisHash(c.Ctx, c.RoomID, string(displayCount))
+	if err != nil {
+		return false
+	}
+	displays, err := strconv.Atoi(count)
+	return err == nil && displays > 0
+}
+
+// The events a display may send. Displays only move between rooms.
+var displayEvents = map[gameEvent]bool{
+	create:  true,
+	join:    true,
+	display: true,
+	lobby:   true,
+	leave:   true,
+	CloseWS: true,
+}
+
+// Connects the display to the room with the given code (if it exists) and
+// shows the page the room is on.
+func (c *Client) handleDisplay(p *roomCodePayload) {
+	roomID, exists, err := roomRepo.resolveCode(c.Ctx, p.Code)
+	if err != nil {
+		log.Printf("Error looking up room code: %v", err)
+		return
+	}
+	if !exists {
+		c.sendNotice("There is no room with that code")
+		return
+	}
+	err = c.joinRoom(roomID)
+	if err != nil {
+		log.Printf("Error joining room: %v", err)
+		return
+	}
+	c.Mutex.Lock()
+	c.Display = true
+	c.Mutex.Unlock()
+	c.startDisplaying()
+}
+
+// Sends the display the page the room is on and tells the room about the display.
+// Displays that join before the first round are sent the display's waiting room,
+// which the room fills in by publishing its player list.
+func (c *Client) startDisplaying() {
+	err := c.backupClientData()
+	if err != nil {
+		log.Println(err)
+	}
+	err = c.restoreView()
+	if err != nil {
+		code, err := c.fetchRoomCode()
+		if err != nil {
+			log.Printf("Error fetching room code: %v", err)
+		}
+		wpd := &waitingPageData{RoomCode: code, Spectating: true}
+		err = sendPage(c, waitingPage, wpd, generateDisplayWaitingPage)
+		if err != nil {
+			log.Printf("Error creating display waiting page template: %v", err)
+			return
+		}
+	}
+	displayMsg, err := json.Marshal(newPSMessage(newDisplay, c.UserID, ""))
+	if err != nil {
+		log.Printf("Error encoding new display message: %v", err)
+		return
+	}
+	err = publishClientMessage(c, displayMsg)
+	if err != nil {
+		log.Printf("Error publishing new display: %v", err)
+	}
+}
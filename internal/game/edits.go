@@ -0,0 +1,111 @@
+package game
+
+import (
+	"github.com/redis/go-redis/v9"
+)
+
+// A type that represents how often rounds build on the previous winner's picture.
+type editSchedule string
+
+const (
+	noEdits        editSchedule = "off"       // Every round starts from scratch
+	alternateEdits editSchedule = "alternate" // Every other round edits the previous winner
+	alwaysEdit     editSchedule = "always"    // Every round after the first edits the previous winner
+)
+
+// The edit schedules in the order they are offered in the room settings.
+var editSchedules = []editSchedule{noEdits, alternateEdits, alwaysEdit}
+
+// Returns a short human readable explanation of the edit schedule.
+func (s editSchedule) description() string {
+	switch s {
+	case alternateEdits:
+		return "Every other round, edit the last winning picture"
+	case alwaysEdit:
+		return "Every round, edit the last winning picture"
+	default:
+		return "Every round starts from scratch"
+	}
+}
+
+// Reports whether s is a known edit schedule.
+func (s editSchedule) valid() bool {
+	for _, schedule := range editSchedules {
+		if s == schedule {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether the round with the given number edits the previous winner.
+func (s editSchedule) editsRound(number int) bool {
+	switch s {
+	case alternateEdits:
+		return number%2 == 0
+	case alwaysEdit:
+		return true
+	default:
+		return false
+	}
+}
+
+// The winning picture of a round.
+type roundWinner struct {
+	Round    int
+	UserID   string
+	Username string
+	URL      string
+	Prompt   string
+}
+
+// Picks the winning picture of a round: the picture that received the most points.
+// Ties go to the picture submitted first. Returns false if no picture received points.
+func pickWinner(result *RoundResult) (roundWinner, bool) {
+	var best Submission
+	most := 0
+	for authorID, votes := range result.votesReceived() {
+		submission, ok := result.Submissions[authorID]
+		if !ok || submission.URL == "" {
+			continue
+		}
+		if votes > most || (votes == most && submission.SubmittedAt.Before(best.SubmittedAt)) {
+			best, most = submission, votes
+		}
+	}
+	if most == 0 {
+		return roundWinner{}, false
+	}
+	return roundWinner{
+		Round:    result.Number,
+		UserID:   best.UserID,
+		Username: result.Players[best.UserID],
+		URL:      best.URL,
+		Prompt:   best.Prompt,
+	}, true
+}
+
+// Gets the most recent winning picture.
+// Must be called with the room's mutex held.
+func (r *Room) lastWinner() (roundWinner, bool) {
+	if len(r.Winners) == 0 {
+		return roundWinner{}, false
+	}
+	return r.Winners[len(r.Winners)-1], true
+}
+
+// Backs up the picture players edit in the current round, so clients know to
+// edit it rather than generate a new picture. Clears the backup for fresh rounds.
+func (r *Room) backupBasePicture(url string) error {
+	return setRedisHash(r.Ctx, r.ID, string(basePicture), url)
+}
+
+// Fetches the picture players edit in the room's current round.
+// Returns an empty string if the round starts from scratch.
+func (c *Client) fetchBasePicture() (string, error) {
+	url, err := getRedisHash(c.Ctx, c.RoomID, string(basePicture))
+	if err == redis.Nil {
+		return "", nil
+	}
+	return url, err
+}
@@ -4,20 +4,56 @@ package game
 type gameEvent string
 
 const (
-	create          gameEvent = "create-room"      // Room been created
-	join            gameEvent = "join-room"        // Room been joined
-	setUsername     gameEvent = "set-username"     // User set username
-	newUser         gameEvent = "new-user"         // New user joined
-	newPlayerList   gameEvent = "new-player-list"  // Player list updated
-	ready           gameEvent = "ready"            // User is ready for next round
-	enterGame       gameEvent = "game-room"        // Game started
-	prompt          gameEvent = "prompt"           // User submitted prompt
-	getPicture      gameEvent = "get-picture"      // Get user's chosen picture
-	pickPicture     gameEvent = "pick-picture"     // User picked picture
-	votePage        gameEvent = "vote-page"        // Send the page of candidates
-	vote            gameEvent = "vote"             // User voted
-	sendLeaderboard gameEvent = "send-leaderboard" // Send the current leaderboard
-	leave           gameEvent = "leave"            // User left game
-	reconnect       gameEvent = "reconnect"        // User has reconnected
-	CloseWS         gameEvent = "close-ws"         // Unexpected WebSocket disconnection.
+	create           gameEvent = "create-room"        // Room been created
+	join             gameEvent = "join-room"          // Room been joined
+	display          gameEvent = "display-room"       // Display joined a room to show it on a shared screen
+	lobby            gameEvent = "lobby"              // User opened the lobby
+	quickMatch       gameEvent = "quick-match"        // User joined the quick-match queue
+	cancelMatch      gameEvent = "cancel-quick-match" // User left the quick-match queue
+	quickMatchStatus gameEvent = "quick-match-status" // Number of players waiting for a quick match
+	matched          gameEvent = "matched"            // User was matched into a room
+	setUsername      gameEvent = "set-username"       // User set username
+	usernamePage     gameEvent = "username-page"      // Ask the user to pick a username
+	newUser          gameEvent = "new-user"           // New user joined
+	newSpectator     gameEvent = "new-spectator"      // Spectator joined or reconnected
+	newDisplay       gameEvent = "new-display"        // Display joined or reconnected
+	queued           gameEvent = "queued"             // New user waits for the next round
+	promoted         gameEvent = "promoted"           // Queued user joined the game
+	newPlayerList    gameEvent = "new-player-list"    // Player list updated
+	waitingPage      gameEvent = "waiting-page"       // Send the waiting room
+	updateSettings   gameEvent = "update-settings"    // Host changed room settings
+	newSettings      gameEvent = "new-settings"       // Room settings updated
+	ready            gameEvent = "ready"              // User is ready for next round
+	askPage          gameEvent = "ask-page"           // Ask players to write a question
+	question         gameEvent = "question"           // User wrote a question
+	questionVotePage gameEvent = "question-vote-page" // Send the questions to vote on
+	questionVote     gameEvent = "question-vote"      // User voted for a question
+	enterGame        gameEvent = "game-room"          // Game started
+	prompt           gameEvent = "prompt"             // User submitted prompt
+	picturePreview   gameEvent = "picture-preview"    // Send the picture generated for a prompt
+	getPicture       gameEvent = "get-picture"        // Get user's chosen picture
+	pickPicture      gameEvent = "pick-picture"       // User picked picture
+	telephonePage    gameEvent = "telephone-page"     // Send every player's chain assignment
+	chainReveal      gameEvent = "chain-reveal"       // Reveal the finished chains
+	huddlePage       gameEvent = "huddle-page"        // Send each team its pictures
+	teamVote         gameEvent = "team-vote"          // User voted for their team's picture
+	votePage         gameEvent = "vote-page"          // Send the page of candidates
+	guessPage        gameEvent = "guess-page"         // Send the picture to guess
+	guess            gameEvent = "guess"              // User guessed a prompt
+	audienceVote     gameEvent = "audience-vote"      // Spectator voted for a picture
+	vote             gameEvent = "vote"               // User voted
+	chat             gameEvent = "chat"               // User sent a chat message
+	newChatMessage   gameEvent = "new-chat-message"   // Relay a chat message to the room
+	chatPanel        gameEvent = "chat-panel"         // Send the chat panel with the room's recent messages
+	react            gameEvent = "react"              // User reacted with an emoji
+	newReaction      gameEvent = "new-reaction"       // Relay a reaction to the room
+	notice           gameEvent = "notice"             // Send a notice to one user
+	rejected         gameEvent = "rejected"           // A client message was malformed
+	unknownVersion   gameEvent = "unknown-version"    // A client message used a protocol version the server doesn't speak
+	sendLeaderboard  gameEvent = "send-leaderboard"   // Send the current leaderboard
+	closeRoom        gameEvent = "close-room"         // Host closed the room through the API
+	roomClosed       gameEvent = "room-closed"        // Room was closed by its host
+	leave            gameEvent = "leave"              // User left game
+	reconnect        gameEvent = "reconnect"          // User has reconnected
+	CloseWS          gameEvent = "close-ws"           // Unexpected WebSocket disconnection.
 )
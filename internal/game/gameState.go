@@ -5,12 +5,32 @@ package game
 type gameState string
 
 const (
-	isReady     gameState = "is-ready"     // Player is ready
-	isNotReady  gameState = "is-not-ready" // Player is not ready
-	picture     gameState = "picture"      // A picture URL
-	username    gameState = "username"     // A player username
-	roomList    gameState = "room-list"    // The global list of all rooms
-	roomID      gameState = "room-id"      // The id of a room
-	leaderboard gameState = "leaderboard"  // The leaderboard for a room
-	roomBackup  gameState = "room-backup"  // A room's backup
+	isReady          gameState = "is-ready"        // Player is ready
+	isNotReady       gameState = "is-not-ready"    // Player is not ready
+	picture          gameState = "picture"         // A picture URL
+	promptText       gameState = "prompt"          // The prompt for a picture
+	username         gameState = "username"        // A player username
+	spectating       gameState = "spectating"      // Whether a user is watching rather than playing
+	queuedStatus     gameState = "queued"          // Whether a player is waiting for the next round
+	displaying       gameState = "displaying"      // Whether a user is a shared display rather than a player
+	roomList         gameState = "room-list"       // The global list of all rooms
+	publicRooms      gameState = "public-rooms"    // The lobby listings of all public rooms
+	quickMatchQueue  gameState = "quick-match"     // The players waiting for a quick match
+	clientSessions   gameState = "client-sessions" // The fallback sessions of clients without WebSockets
+	webhookQueue     gameState = "webhook-queue"   // The webhook deliveries waiting to be sent
+	webhookLog       gameState = "webhook-log"     // The latest webhook delivery attempts
+	roomCodes        gameState = "room-codes"      // The room codes in use, mapped to their rooms
+	roomCode         gameState = "room-code"       // The code players use to join a room
+	displayCount     gameState = "display-count"   // The number of displays showing a room
+	roomID           gameState = "room-id"         // The id of a room
+	chatHistory      gameState = "chat"            // A room's recent chat messages
+	chatRate         gameState = "chat-rate"       // The number of chat messages a user sent recently
+	leaderboard      gameState = "leaderboard"     // The leaderboard for a room
+	roomStatus       gameState = "status"          // A room's status, served by the API
+	roundHistory     gameState = "rounds"          // The rounds a room has played
+	roomBackup       gameState = "room-backup"     // A room's backup
+	settingsBackup   gameState = "settings"        // A room's settings and host
+	constraintBackup gameState = "constraint"      // The constraint of a room's current round
+	fakePicture      gameState = "fake-picture"    // A picture made by the fake image generator
+	basePicture      gameState = "base-picture"    // The picture players edit in a room's current round
 )
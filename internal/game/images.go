@@ -0,0 +1,126 @@
+package game
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+
+	"github.com/lithammer/shortuuid"
+)
+
+// Makes the pictures for players' prompts.
+// Pictures are referred to by the URL they are served from.
+type imageGenerator interface {
+	// Generates a picture from a text prompt.
+	generate(ctx context.Context, prompt string) (string, error)
+	// Generates a new picture by editing the picture at url as instructed.
+	edit(ctx context.Context, url, instruction string) (string, error)
+}
+
+// Generates pictures with the OpenAI image APIs.
+type openaiImages struct{}
+
+func (openaiImages) generate(ctx context.Context, prompt string) (string, error) {
+	return generateAIPicture(ctx, prompt)
+}
+
+func (openaiImages) edit(ctx context.Context, url, instruction string) (string, error) {
+	return editAIPicture(ctx, url, instruction)
+}
+
+// Generates placeholder pictures that show their prompt, without any network access.
+// Used for local development and offline play. Pictures are SVGs stored in the
+// database and served from fakePicturePath.
+type fakeImages struct{}
+
+// The size of the pictures made by the fake image generator.
+const fakeImageSize = 512
+
+// The path fake pictures are served from, followed by the picture's id.
+const fakePicturePath = "/pictures/fake/"
+
+func (fakeImages) generate(ctx context.Context, prompt string) (string, error) {
+	return storeFakePicture(ctx, fmt.Sprintf(
+		`<rect width="100%%" height="100%%" fill="%s"/>%s`,
+		fakeColor(prompt), svgCaption(prompt, fakeImageSize/2),
+	))
+}
+
+// Draws the instruction over the original picture. The original is embedded
+// since pictures shown with an img tag cannot load other resources.
+func (fakeImages) edit(ctx context.Context, url, instruction string) (string, error) {
+	original, err := LookupFakePicture(ctx, strings.TrimPrefix(url, fakePicturePath))
+	if err != nil {
+		return "", err
+	}
+	return storeFakePicture(ctx, fmt.Sprintf(
+		`<image href="data:image/svg+xml;base64,%s" width="100%%" height="100%%"/>`+
+			`<rect y="%d" width="100%%" height="%d" fill="%s" opacity="0.8"/>%s`,
+		base64.StdEncoding.EncodeToString(original), fakeImageSize*3/4, fakeImageSize/4,
+		fakeColor(instruction), svgCaption(instruction, fakeImageSize*7/8),
+	))
+}
+
+// Picks a stable background color for a prompt.
+func fakeColor(text string) string {
+	hash := uint32(2166136261)
+	for i := 0; i < len(text); i++ {
+		hash = (hash ^ uint32(text[i])) * 16777619
+	}
+	return fmt.Sprintf("hsl(%d, 60%%, 45%%)", hash%360)
+}
+
+// Writes text centered around the height y of a fake picture.
+func svgCaption(text string, y int) string {
+	return fmt.Sprintf(
+		`<text x="50%%" y="%d" fill="white" font-size="24" text-anchor="middle" dominant-baseline="middle">%s</text>`,
+		y, html.EscapeString(text),
+	)
+}
+
+// Wraps SVG elements into a square picture and stores it in the database.
+// Returns the URL the picture is served from.
+func storeFakePicture(ctx context.Context, body string) (string, error) {
+	svg := fmt.Sprintf(
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">%s</svg>`,
+		fakeImageSize, fakeImageSize, body,
+	)
+	id := shortuuid.New()
+	err := setRedisKey(ctx, fakePictureKey(id), svg)
+	if err != nil {
+		return "", err
+	}
+	return fakePicturePath + id, nil
+}
+
+// Gets the database key of a fake picture.
+func fakePictureKey(id string) string {
+	return fmt.Sprintf("%s:%s", fakePicture, id)
+}
+
+// Looks up the SVG of a picture made by the fake image generator.
+// Errors if the picture does not exist or has expired.
+func LookupFakePicture(ctx context.Context, id string) ([]byte, error) {
+	svg, err := getRedisKey(ctx, fakePictureKey(id))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(svg), nil
+}
+
+// Picks the image generator named by the IMAGE_PROVIDER environment variable.
+// Defaults to OpenAI.
+func newImageGenerator() imageGenerator {
+	switch strings.ToLower(os.Getenv("IMAGE_PROVIDER")) {
+	case "fake":
+		return fakeImages{}
+	default:
+		return openaiImages{}
+	}
+}
+
+// The image generator used for every room.
+var images = newImageGenerator()
@@ -0,0 +1,93 @@
+package game
+
+import "sort"
+
+// A single row of a ranked leaderboard.
+// Rank uses standard competition ranking, so tied players share a rank and the
+// following rank is skipped (1, 2, 2, 4). Delta is the number of points gained
+// in the latest round and RankChange is how many places the player moved
+// (positive means the player climbed). Awards lists how the round's points were earned.
+type leaderboardEntry struct {
+	Rank       int
+	Tied       bool
+	UserID     string
+	Username   string
+	Score      int
+	Delta      int
+	RankChange int
+	Awards     []PointAward
+}
+
+// Returns the number of places the player fell since the previous round.
+func (e leaderboardEntry) RankDrop() int {
+	return -e.RankChange
+}
+
+// Sorts a map of userIDs to scores from highest to lowest score.
+// Ties are broken by userID so the order is stable between renders.
+func sortScores(scores map[string]int) []sortedSetMember {
+	sorted := make([]sortedSetMember, 0, len(scores))
+	for userID, score := range scores {
+		sorted = append(sorted, sortedSetMember{Member: userID, Score: score})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Score != sorted[j].Score {
+			return sorted[i].Score > sorted[j].Score
+		}
+		return sorted[i].Member < sorted[j].Member
+	})
+	return sorted
+}
+
+// Builds a ranked leaderboard from members ordered from highest to lowest score.
+// Members that are not in players are skipped. Tied players are ordered by username.
+// deltas and prevRanks are keyed by userID and may be nil.
+func rankLeaderboard(
+	members []sortedSetMember,
+	players map[string]string,
+	deltas map[string]int,
+	prevRanks map[string]int,
+) []leaderboardEntry {
+	entries := make([]leaderboardEntry, 0, len(members))
+	for _, member := range members {
+		username, ok := players[member.Member]
+		if !ok {
+			continue
+		}
+		entries = append(entries, leaderboardEntry{
+			UserID:   member.Member,
+			Username: username,
+			Score:    member.Score,
+			Delta:    deltas[member.Member],
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		return entries[i].Username < entries[j].Username
+	})
+
+	for i := range entries {
+		if i > 0 && entries[i].Score == entries[i-1].Score {
+			entries[i].Rank = entries[i-1].Rank
+			entries[i].Tied = true
+			entries[i-1].Tied = true
+		} else {
+			entries[i].Rank = i + 1
+		}
+		if prevRank, ok := prevRanks[entries[i].UserID]; ok {
+			entries[i].RankChange = prevRank - entries[i].Rank
+		}
+	}
+	return entries
+}
+
+// Maps each userID on the leaderboard to its rank.
+func leaderboardRanks(entries []leaderboardEntry) map[string]int {
+	ranks := make(map[string]int, len(entries))
+	for _, entry := range entries {
+		ranks[entry.UserID] = entry.Rank
+	}
+	return ranks
+}
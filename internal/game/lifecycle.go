@@ -0,0 +1,121 @@
+package game
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A type that represents the milestones of a room that integrations can listen for.
+type LifecycleEventType string
+
+const (
+	RoomCreated   LifecycleEventType = "room.created"   // A room was created
+	PlayerJoined  LifecycleEventType = "player.joined"  // A player joined the game
+	PlayerLeft    LifecycleEventType = "player.left"    // A player left the game
+	RoundStarted  LifecycleEventType = "round.started"  // A round started with its question
+	RoundFinished LifecycleEventType = "round.finished" // The votes of a round were counted
+	MatchFinished LifecycleEventType = "match.finished" // A room that played at least one round closed
+	RoomClosed    LifecycleEventType = "room.closed"    // A room closed before finishing a round
+)
+
+// Every lifecycle event type, in the order they happen in a match.
+var LifecycleEventTypes = []LifecycleEventType{
+	RoomCreated,
+	PlayerJoined,
+	PlayerLeft,
+	RoundStarted,
+	RoundFinished,
+	MatchFinished,
+	RoomClosed,
+}
+
+// A picture that won a round, as shown in the gallery at the end of a match.
+type WinningPicture struct {
+	Round    int    `json:"round"`
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+	URL      string `json:"url"`
+	Prompt   string `json:"prompt"`
+}
+
+// Something that happened in a room. Only the fields that belong to the event's type are set:
+// Room for created rooms, Player for players joining or leaving, Round for started rounds,
+// Round and Standings for finished rounds, and Standings and Gallery for finished matches.
+// Closed rooms have no fields set.
+type LifecycleEvent struct {
+	ID        string             `json:"id"`
+	Type      LifecycleEventType `json:"type"`
+	RoomCode  string             `json:"roomCode"`
+	Time      time.Time          `json:"time"`
+	Room      *RoomStatus        `json:"room,omitempty"`
+	Player    *RoomMember        `json:"player,omitempty"`
+	Round     *RoundRecord       `json:"round,omitempty"`
+	Standings []LeaderboardEntry `json:"standings,omitempty"`
+	Gallery   []WinningPicture   `json:"gallery,omitempty"`
+}
+
+// The functions called with every lifecycle event of the rooms this server runs.
+var lifecycleListeners = struct {
+	sync.RWMutex
+	listeners []func(*LifecycleEvent)
+}{}
+
+// Registers a function to call with every lifecycle event of the rooms this server runs.
+// Each event is passed to its own goroutine, so listeners must not expect events in order.
+func OnLifecycleEvent(listener func(*LifecycleEvent)) {
+	lifecycleListeners.Lock()
+	defer lifecycleListeners.Unlock()
+	lifecycleListeners.listeners = append(lifecycleListeners.listeners, listener)
+}
+
+// Stamps the event with the room's code and passes it to every listener.
+func (r *Room) emitLifecycleEvent(event *LifecycleEvent) {
+	event.ID = uuid.NewString()
+	event.RoomCode = r.Code
+	event.Time = time.Now()
+
+	lifecycleListeners.RLock()
+	defer lifecycleListeners.RUnlock()
+	for _, listener := range lifecycleListeners.listeners {
+		go listener(event)
+	}
+}
+
+// Converts ranked leaderboard entries into the leaderboard served to integrations.
+func publicLeaderboard(lb []leaderboardEntry) []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(lb))
+	for _, entry := range lb {
+		entries = append(entries, LeaderboardEntry{
+			Rank:     entry.Rank,
+			Tied:     entry.Tied,
+			UserID:   entry.UserID,
+			Username: entry.Username,
+			Score:    entry.Score,
+		})
+	}
+	return entries
+}
+
+// Emits the end of the match, with the final standings and the gallery of round winners.
+// Rooms that never finished a round emit RoomClosed instead, so every room emits exactly
+// one event when it closes.
+func (r *Room) finishMatch() {
+	r.Mutex.RLock()
+	standings := publicLeaderboard(r.Standings)
+	gallery := make([]WinningPicture, 0, len(r.Winners))
+	for _, winner := range r.Winners {
+		gallery = append(gallery, WinningPicture(winner))
+	}
+	r.Mutex.RUnlock()
+	if len(standings) == 0 {
+		r.emitLifecycleEvent(&LifecycleEvent{Type: RoomClosed})
+		return
+	}
+	r.emitLifecycleEvent(&LifecycleEvent{
+		Type:      MatchFinished,
+		Standings: standings,
+		Gallery:   gallery,
+	})
+}
@@ -0,0 +1,172 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// The longest room name the host can pick.
+const maxRoomNameLength = 40
+
+// Validates the room name picked in the settings form.
+func parseRoomName(value string) (string, error) {
+	name := strings.TrimSpace(value)
+	if len(name) > maxRoomNameLength {
+		return "", fmt.Errorf("Room names can be at most %d characters", maxRoomNameLength)
+	}
+	return name, nil
+}
+
+// Parses whether the room is listed in the lobby, as picked in the settings form.
+func parseVisibility(value string) (bool, error) {
+	switch value {
+	case "public":
+		return true, nil
+	case "private":
+		return false, nil
+	default:
+		return false, errors.New("Unknown room visibility: " + value)
+	}
+}
+
+// Returns a short human readable explanation of the room's visibility.
+func visibilityDescription(public bool) string {
+	if public {
+		return "Public: listed in the lobby"
+	}
+	return "Private: join with the room code"
+}
+
+// A public room as listed in the lobby.
+// Open rooms have not started yet, so new players join straight away.
+type lobbyListing struct {
+	ID       string
+	Code     string
+	Name     string
+	Players  int
+	Open     bool
+	Phase    string
+	Settings []string
+}
+
+// Describes what a room is doing for the lobby.
+// Must be called with the room's mutex held.
+func (r *Room) describePhase() string {
+	switch {
+	case r.State == waiting:
+		return "Waiting to start"
+	case r.State == scoring:
+		return "Between rounds"
+	case r.Round != nil:
+		return fmt.Sprintf("Playing round %d", r.Round.Number)
+	default:
+		return "Playing"
+	}
+}
+
+// Builds the room's lobby listing.
+// Must be called with the room's mutex held.
+func (r *Room) getListing() *lobbyListing {
+	name := r.Settings.Name
+	if host, ok := r.Players[r.Host]; name == "" && ok {
+		name = fmt.Sprintf("%s's room", host)
+	} else if name == "" {
+		name = "Prompt and Paint room"
+	}
+	listing := &lobbyListing{
+		ID:       r.ID,
+		Code:     r.Code,
+		Name:     name,
+		Players:  len(r.Players),
+		Open:     r.acceptsPlayers(),
+		Phase:    r.describePhase(),
+		Settings: []string{r.Settings.GameMode.description(), r.Settings.VotingMode.description()},
+	}
+	if r.Settings.Teams > 0 {
+		listing.Settings = append(listing.Settings, fmt.Sprintf("%d teams", r.Settings.Teams))
+	}
+	return listing
+}
+
+// Updates the room's lobby listing and its status for the room API.
+// Private rooms are taken off the lobby.
+func (r *Room) refreshListing() {
+	r.Mutex.RLock()
+	public := r.Settings.Public
+	listing := r.getListing()
+	status := r.getStatus()
+	r.Mutex.RUnlock()
+
+	err := r.backupStatus(status)
+	if err != nil {
+		log.Printf("Error backing up room status: %v", err)
+	}
+	if public {
+		err = roomRepo.listRoom(r.Ctx, listing)
+	} else {
+		err = roomRepo.unlistRoom(r.Ctx, r.ID)
+	}
+	if err != nil {
+		log.Printf("Error updating lobby listing: %v", err)
+	}
+}
+
+// Adds or updates a public room in the lobby.
+func (g *roomRepository) listRoom(ctx context.Context, listing *lobbyListing) error {
+	listingJSON, err := json.Marshal(listing)
+	if err != nil {
+		return err
+	}
+	return setRedisHash(ctx, g.publicRooms, listing.ID, listingJSON)
+}
+
+// Takes a room off the lobby.
+func (g *roomRepository) unlistRoom(ctx context.Context, roomID string) error {
+	return deleteFromRedisHash(ctx, g.publicRooms, roomID)
+}
+
+// Gets every public room, with the open rooms first and then the fullest rooms.
+func (g *roomRepository) listPublicRooms(ctx context.Context) ([]lobbyListing, error) {
+	listingsJSON, err := getAllRedisHash(ctx, g.publicRooms)
+	if err != nil {
+		return nil, err
+	}
+	listings := make([]lobbyListing, 0, len(listingsJSON))
+	for _, listingJSON := range listingsJSON {
+		listing := lobbyListing{}
+		err := json.Unmarshal([]byte(listingJSON), &listing)
+		if err != nil {
+			log.Printf("Error parsing lobby listing: %v", err)
+			continue
+		}
+		listings = append(listings, listing)
+	}
+	sort.Slice(listings, func(i, j int) bool {
+		if listings[i].Open != listings[j].Open {
+			return listings[i].Open
+		}
+		if listings[i].Players != listings[j].Players {
+			return listings[i].Players > listings[j].Players
+		}
+		return listings[i].Name < listings[j].Name
+	})
+	return listings, nil
+}
+
+// Sends the lobby, which lists every public room.
+func (c *Client) handleLobby() {
+	listings, err := roomRepo.listPublicRooms(c.Ctx)
+	if err != nil {
+		log.Printf("Error fetching public rooms: %v", err)
+		return
+	}
+	err = sendPage(c, lobby, &lobbyPageData{Rooms: listings}, generateLobbyPage)
+	if err != nil {
+		log.Printf("Error creating lobby template: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// The number of players the quick-match queue waits for before starting a room.
+const quickMatchSize = 4
+
+// Adds the user to the quick-match queue, unless they are already waiting in it.
+// Once enough players are waiting, the first of them are grouped into a new private
+// room hosted by whoever waited longest. Players are put back in the queue if the
+// room can't be created, and matched rooms nobody joins are deleted after a while.
+// Returns the number of players still waiting.
+func enqueueQuickMatch(ctx context.Context, userID string) (int, error) {
+	group, remaining, err := pushAndPopRedisListGroup(ctx, string(quickMatchQueue), userID, quickMatchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(group) < quickMatchSize {
+		return int(remaining), nil
+	}
+
+	room, err := createRoom(group[0])
+	if err != nil {
+		requeueErr := unpopRedisList(ctx, string(quickMatchQueue), group)
+		if requeueErr != nil {
+			log.Printf("Error returning players to quick match queue: %v", requeueErr)
+		}
+		return 0, err
+	}
+	time.AfterFunc(unclaimedRoomTimeout, room.deleteIfUnclaimed)
+	room.Mutex.Lock()
+	room.Settings.Name = "Quick match"
+	room.Mutex.Unlock()
+	err = room.backupSettings()
+	if err != nil {
+		log.Printf("Error backing up room settings: %v", err)
+	}
+	for _, memberID := range group {
+		matchedMsg := newPSMessage(matched, room.ID, room.ID)
+		matchedMsg.Recipient = memberID
+		err := publishQuickMatchMessage(ctx, matchedMsg)
+		if err != nil {
+			log.Printf("Error publishing quick match: %v", err)
+		}
+	}
+	return int(remaining), nil
+}
+
+// Takes the user out of the quick-match queue.
+// Returns the number of players still waiting.
+func dequeueQuickMatch(ctx context.Context, userID string) (int, error) {
+	err := deleteFromRedisList(ctx, string(quickMatchQueue), userID)
+	if err != nil {
+		return 0, err
+	}
+	length, err := lengthRedisList(ctx, string(quickMatchQueue))
+	return int(length), err
+}
+
+// Publishes a message to everyone waiting in the quick-match queue.
+func publishQuickMatchMessage(ctx context.Context, msg *PSMessage) error {
+	msgJSON, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return publishChannelMessage(ctx, string(quickMatchQueue), msgJSON)
+}
+
+// Tells everyone waiting in the quick-match queue how many players are waiting.
+func publishQuickMatchCount(ctx context.Context, count int) {
+	err := publishQuickMatchMessage(ctx, newPSMessage(quickMatchStatus, "", strconv.Itoa(count)))
+	if err != nil {
+		log.Printf("Error publishing quick match status: %v", err)
+	}
+}
+
+// Puts the user in the quick-match queue and waits for a room to be found.
+func (c *Client) handleQuickMatch() {
+	c.Mutex.Lock()
+	if c.RoomID != "" || c.StopMatching != nil {
+		c.Mutex.Unlock()
+		return
+	}
+	matchCtx, stop := context.WithCancel(c.Ctx)
+	c.StopMatching = stop
+	c.Mutex.Unlock()
+
+	pubsub, err := subscribeChannel(matchCtx, string(quickMatchQueue))
+	if err != nil {
+		log.Printf("Error subscribing to quick match: %v", err)
+		c.stopQuickMatch()
+		return
+	}
+	go c.awaitQuickMatch(matchCtx, pubsub)
+
+	count, err := enqueueQuickMatch(c.Ctx, c.UserID)
+	if err != nil {
+		log.Printf("Error joining quick match: %v", err)
+		c.leaveQuickMatch()
+		c.sendNotice("Unable to find a quick match, please try again")
+		return
+	}
+	publishQuickMatchCount(c.Ctx, count)
+}
+
+// Takes the user out of the quick-match queue, if they are waiting in it.
+func (c *Client) leaveQuickMatch() {
+	if !c.stopQuickMatch() {
+		return
+	}
+	count, err := dequeueQuickMatch(c.Ctx, c.UserID)
+	if err != nil {
+		log.Printf("Error leaving quick match: %v", err)
+		return
+	}
+	publishQuickMatchCount(c.Ctx, count)
+}
+
+// Stops waiting for a quick match. Returns false if the user was not waiting.
+func (c *Client) stopQuickMatch() bool {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	if c.StopMatching == nil {
+		return false
+	}
+	c.StopMatching()
+	c.StopMatching = nil
+	return true
+}
+
+// Reads the quick-match channel until the user is matched into a room or stops waiting.
+// Matched users join the room and are sent to the username page.
+func (c *Client) awaitQuickMatch(ctx context.Context, pubsub *redis.PubSub) {
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+
+	for {
+		select {
+		case msg := <-ch:
+			psEvent := PSMessage{}
+			err := json.Unmarshal([]byte(msg.Payload), &psEvent)
+			if err != nil {
+				log.Printf("Error unmarshalling pubsub message: %v", err)
+				continue
+			}
+
+			switch psEvent.Event {
+			case quickMatchStatus:
+				go c.displayQuickMatch(psEvent.Msg)
+			case matched:
+				if psEvent.Recipient == c.UserID {
+					c.stopQuickMatch()
+					go c.joinMatch(psEvent.Msg)
+					return
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Shows how many players are waiting for a quick match.
+func (c *Client) displayQuickMatch(count string) {
+	waiting, err := strconv.Atoi(count)
+	if err != nil {
+		log.Printf("Error parsing quick match status: %v", err)
+		return
+	}
+	qpd := &quickMatchPageData{Waiting: waiting, Size: quickMatchSize}
+	err = sendPage(c, quickMatchStatus, qpd, generateQuickMatchPage)
+	if err != nil {
+		log.Printf("Error creating quick match template: %v", err)
+	}
+}
+
+// Joins the room the user was matched into and sends the user to the username page.
+func (c *Client) joinMatch(roomID string) {
+	err := c.joinRoom(roomID)
+	if err != nil {
+		log.Printf("Error joining room: %v", err)
+		return
+	}
+	c.sendUsernamePage()
+}
@@ -0,0 +1,38 @@
+package game
+
+// A type that represents the rules a room plays by.
+type gameMode string
+
+const (
+	classicMode   gameMode = "classic"   // Everyone paints and everyone votes
+	judgeMode     gameMode = "judge"     // A rotating judge picks the winning picture
+	reverseMode   gameMode = "reverse"   // Players guess the prompt behind a picture
+	telephoneMode gameMode = "telephone" // Players pass pictures along a chain
+)
+
+// The game modes in the order they are offered in the room settings.
+var gameModes = []gameMode{classicMode, judgeMode, reverseMode, telephoneMode}
+
+// Returns a short human readable explanation of the game mode.
+func (m gameMode) description() string {
+	switch m {
+	case judgeMode:
+		return "Judge: a rotating judge picks the winner (one point per win)"
+	case reverseMode:
+		return "Reverse: guess the prompt behind a player's picture"
+	case telephoneMode:
+		return "Telephone: describe the picture passed to you and see how it changes"
+	default:
+		return "Classic: everyone paints and votes"
+	}
+}
+
+// Reports whether m is a known game mode.
+func (m gameMode) valid() bool {
+	for _, mode := range gameModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
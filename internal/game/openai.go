@@ -3,7 +3,12 @@ package game
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
 	"log"
+	"net/http"
 	"os"
 
 	"github.com/sashabaranov/go-openai"
@@ -82,3 +87,81 @@ func generateAIPicture(ctx context.Context, prompt string) (string, error) {
 	}
 	return resp.Data[0].URL, nil
 }
+
+// Edits the picture at url following the provided instruction.
+// The picture is downloaded and sent with a fully transparent mask, so the
+// whole picture may change. Errors if the download or the OpenAI API errors.
+func editAIPicture(ctx context.Context, url, instruction string) (string, error) {
+	picture, err := downloadPicture(ctx, url)
+	if err != nil {
+		log.Printf("Error downloading picture to edit: %v", err)
+		return "", err
+	}
+	imageFile, err := writeTempPNG(picture)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(imageFile.Name())
+	defer imageFile.Close()
+	maskFile, err := writeTempPNG(image.NewRGBA(picture.Bounds()))
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(maskFile.Name())
+	defer maskFile.Close()
+
+	req := openai.ImageEditRequest{
+		Image:          imageFile,
+		Mask:           maskFile,
+		Prompt:         instruction,
+		Model:          openai.CreateImageModelDallE2,
+		Size:           openai.CreateImageSize1024x1024,
+		ResponseFormat: openai.CreateImageResponseFormatURL,
+		N:              1,
+	}
+	resp, err := openaiClient.CreateEditImage(ctx, req)
+	if err != nil {
+		log.Printf("Error editing image: %v", err)
+		return "", err
+	}
+	return resp.Data[0].URL, nil
+}
+
+// Downloads and decodes the picture at url.
+func downloadPicture(ctx context.Context, url string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected status downloading picture: %s", resp.Status)
+	}
+	picture, _, err := image.Decode(resp.Body)
+	return picture, err
+}
+
+// Writes a picture to a temporary RGBA PNG file, as the image edit API requires.
+// The file is rewound so it can be uploaded.
+func writeTempPNG(picture image.Image) (*os.File, error) {
+	rgba := image.NewRGBA(picture.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), picture, picture.Bounds().Min, draw.Src)
+	f, err := os.CreateTemp("", "picture-*.png")
+	if err != nil {
+		return nil, err
+	}
+	err = png.Encode(f, rgba)
+	if err == nil {
+		_, err = f.Seek(0, 0)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return f, nil
+}
@@ -1,23 +1,188 @@
 package game
 
+// Holds data needed to create the username page from its template.
+type usernamePageData struct {
+	RoomCode string
+}
+
 // Holds data needed to create the waiting page from its template.
+// Spectators are not shown the ready button. Compact hides the invite QR code
+// when a display already shows it.
 type waitingPageData struct {
-	RoomID string
+	RoomCode   string
+	Spectating bool
+	Compact    bool
+}
+
+// Holds data needed to create the lobby from its template.
+type lobbyPageData struct {
+	Rooms []lobbyListing
+}
+
+// Holds data needed to create the quick-match page from its template.
+type quickMatchPageData struct {
+	Waiting int
+	Size    int
 }
 
 // Holds data needed to create the player list from its template.
+// Queued players joined mid-game and wait for the next round.
 type playerListData struct {
-	Players map[string]string
+	Players    map[string]string
+	Queued     map[string]string
+	Spectators map[string]string
+}
+
+// Holds data needed to create the question page from its template.
+// Players write a question, then vote for one of the Candidates if Voting is set.
+// UserID and Spectating are set by each client so players can't vote for their
+// own question and spectators only watch.
+type questionPageData struct {
+	Voting     bool
+	Candidates []Candidate
+	MaxLength  int
+	UserID     string `json:"-"`
+	Spectating bool   `json:"-"`
 }
 
 // Holds data needed to create the game page from its template.
+// QuestionAuthor is only set when a player wrote the question.
+// JudgeID and JudgeName are only set in judge mode, ArtistID and ArtistName
+// only in reverse mode. Teams is only set when the room plays in teams, and
+// Team and IsCaptain are decided by each client. Players are sent a Compact
+// controller view when a display shows the round, and displays count down
+// from Countdown seconds.
 type gamePageData struct {
-	Question string
+	Question         string
+	QuestionAuthorID string
+	QuestionAuthor   string
+	Constraint       *constraint
+	Base             *roundWinner
+	JudgeID          string
+	JudgeName        string
+	ArtistID         string
+	ArtistName       string
+	Teams            []team
+	TeamVoting       bool
+	Team             *team `json:"-"`
+	IsCaptain        bool  `json:"-"`
+	Compact          bool  `json:"-"`
+	Countdown        int   `json:"-"`
+}
+
+// A team's pictures for the team to vote on.
+type huddleTeam struct {
+	team
+	Candidates []Candidate
+}
+
+// Holds data needed to create the huddle page from its template.
+// Team is picked out by each client.
+type huddlePageData struct {
+	Teams []huddleTeam
+	Team  *huddleTeam `json:"-"`
+}
+
+// Holds data needed to create the telephone page from its template.
+// Assignments maps each player to the chain they are working on. Players
+// without an assignment are waiting for a chain to be passed to them.
+// Assignment is picked out by each client.
+type telephonePageData struct {
+	Round       int
+	Question    string
+	Constraint  *constraint
+	Length      int
+	Assignments map[string]*assignment
+	Assignment  *assignment `json:"-"`
+	Spectating  bool        `json:"-"`
+}
+
+// Holds data needed to create the chain reveal page from its template.
+// Spectators are not shown the ready button.
+type chainRevealData struct {
+	Question   string
+	Constraint *constraint
+	Chains     []chain
+	Spectating bool `json:"-"`
+}
+
+// Holds data needed to create the guessing page from its template.
+// IsArtist and Spectating are decided by each client.
+type guessingPageData struct {
+	URL        string
+	ArtistID   string
+	ArtistName string
+	IsArtist   bool `json:"-"`
+	Spectating bool `json:"-"`
 }
 
 // Holds data needed to create the voting page from its template.
+// Ranks, Choices and Budget are only used by the ranked and budget voting modes.
+// Choices holds the candidates the viewer ranks, leaving out the pictures listed for
+// them in OwnCandidates, which their team submitted.
+// CanVote is decided by each client, since only the judge votes in judge mode.
+// Spectators vote for their favorite picture instead when Audience is set.
 type votingPageData struct {
-	URLs []string
+	Mode          votingMode
+	Candidates    []Candidate
+	Choices       []Candidate `json:"-"`
+	Ranks         []int
+	OwnCandidates map[string][]string
+	Budget        int
+	JudgeID       string
+	JudgeName     string
+	ArtistID      string
+	ArtistName    string
+	PictureURL    string
+	Audience      bool
+	CanVote       bool     `json:"-"`
+	Spectating    bool     `json:"-"`
+	Reactions     []string `json:"-"`
+}
+
+// A single choice for a setting as shown in the room settings panel.
+type settingOption struct {
+	Value    string
+	Label    string
+	Selected bool
+}
+
+// Holds data needed to create the room settings panel from its template.
+// Only the host is shown the settings form.
+// RoomName and Visibilities control how the room is listed in the lobby.
+type settingsPanelData struct {
+	IsHost          bool
+	RoomName        string
+	MaxNameLength   int
+	Visibilities    []settingOption
+	GameModes       []settingOption
+	QuestionSources []settingOption
+	ConstraintDecks []settingOption
+	EditSchedules   []settingOption
+	ScoringRules    []settingOption
+	VotingModes     []settingOption
+	TeamCounts      []settingOption
+	TeamSubmissions []settingOption
+	AudienceBonuses []settingOption
+}
+
+// Holds data needed to create a notice shown above the current page.
+type noticeData struct {
+	Message string
+}
+
+// Holds data needed to tell a client that one of its messages was rejected.
+// Shown to HTML clients as a notice.
+type rejectionData struct {
+	Event   gameEvent
+	Message string
+}
+
+// Holds data needed to tell a client that the server doesn't speak the protocol
+// version of its message. Shown to HTML clients as a notice.
+type versionData struct {
+	Message   string
+	Supported []int
 }
 
 // Holds data needed to create the image preview from its template.
@@ -26,7 +191,21 @@ type imagePreviewData struct {
 }
 
 // Holds data needed to create the leaderboard page from its template.
+// Both tables are ordered by rank. Answer and AnswerURL reveal the real
+// prompt and picture in reverse mode. QuestionAuthor credits the player who
+// wrote the round's question. TeamLeaderboard is only set when the room plays in teams.
+// Spectators are not shown the ready button, and players are sent a Compact
+// view without the round's results when a display shows them.
 type leaderboardPageData struct {
-	Scores      map[string]int
-	Leaderboard map[string]int
+	Question        string
+	QuestionAuthor  string
+	Constraint      *constraint
+	Winner          *roundWinner
+	Scores          []leaderboardEntry
+	Leaderboard     []leaderboardEntry
+	TeamLeaderboard []leaderboardEntry
+	Answer          string
+	AnswerURL       string
+	Spectating      bool `json:"-"`
+	Compact         bool `json:"-"`
 }
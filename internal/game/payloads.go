@@ -0,0 +1,274 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// The largest WebSocket message the server reads from a client, in bytes.
+// Connections that send anything larger are closed.
+const MaxMessageSize = 16 * 1024
+
+const (
+	maxUsernameLength = 24   // The longest username a player can pick
+	maxPromptLength   = 400  // The longest prompt or guess a player can write
+	maxIDLength       = 64   // The longest candidate ID a player can vote for
+	maxURLLength      = 2048 // The longest picture URL a player can submit
+	maxFormFields     = 32   // The most form inputs a settings form or ballot can have
+	maxFieldLength    = 100  // The longest value of a settings or ballot input
+	maxChatLength     = 200  // The longest chat message a user can send
+)
+
+// Usernames are a single word, as the username form asks for.
+var usernamePattern = regexp.MustCompile(`^\w+$`)
+
+// The typed input a client sends with an event, decoded from the msg and form
+// fields of its GameMessage.
+type payload interface {
+	// Decodes and validates the payload. Errors are shown to the client.
+	parse(gameMsg *GameMessage) error
+}
+
+// Maps each event a client may send to the type of its payload.
+// Events that are not listed are rejected.
+var payloadTypes = map[gameEvent]func() payload{
+	create:         func() payload { return &emptyPayload{} },
+	join:           func() payload { return &roomCodePayload{} },
+	display:        func() payload { return &roomCodePayload{} },
+	lobby:          func() payload { return &emptyPayload{} },
+	quickMatch:     func() payload { return &emptyPayload{} },
+	cancelMatch:    func() payload { return &emptyPayload{} },
+	setUsername:    func() payload { return &usernamePayload{} },
+	updateSettings: func() payload { return &settingsPayload{} },
+	ready:          func() payload { return &emptyPayload{} },
+	question:       func() payload { return &questionPayload{} },
+	questionVote:   func() payload { return &choicePayload{} },
+	prompt:         func() payload { return &promptPayload{} },
+	pickPicture:    func() payload { return &picturePayload{} },
+	guess:          func() payload { return &promptPayload{} },
+	teamVote:       func() payload { return &choicePayload{} },
+	vote:           func() payload { return &ballotPayload{} },
+	audienceVote:   func() payload { return &choicePayload{} },
+	chat:           func() payload { return &chatPayload{} },
+	react:          func() payload { return &reactionPayload{} },
+	leave:          func() payload { return &emptyPayload{} },
+	CloseWS:        func() payload { return &emptyPayload{} },
+}
+
+// Decodes the payload of a client's message. Returns an error the client can
+// be shown if the event is unknown or its input is malformed.
+func parsePayload(gameMsg *GameMessage) (payload, error) {
+	newPayload, ok := payloadTypes[gameMsg.Event]
+	if !ok {
+		return nil, fmt.Errorf("Unknown event: %.*s", maxIDLength, gameMsg.Event)
+	}
+	p := newPayload()
+	err := p.parse(gameMsg)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// The payload of events that carry no input, such as ready signals.
+type emptyPayload struct{}
+
+func (p *emptyPayload) parse(gameMsg *GameMessage) error {
+	return nil
+}
+
+// The room code of the room a client joins, either to play or as a display.
+type roomCodePayload struct {
+	Code string
+}
+
+func (p *roomCodePayload) parse(gameMsg *GameMessage) error {
+	if !IsRoomCode(gameMsg.Msg) {
+		return fmt.Errorf("Room codes are %d to %d letters", minCodeLength, maxCodeLength)
+	}
+	p.Code = normalizeRoomCode(gameMsg.Msg)
+	return nil
+}
+
+// The username a player picked, and whether they chose to only watch.
+type usernamePayload struct {
+	Username string
+	Spectate bool
+}
+
+func (p *usernamePayload) parse(gameMsg *GameMessage) error {
+	username := strings.TrimSpace(gameMsg.Msg)
+	if utf8.RuneCountInString(username) > maxUsernameLength {
+		return fmt.Errorf("Usernames can be at most %d characters", maxUsernameLength)
+	}
+	if !usernamePattern.MatchString(username) {
+		return errors.New("Usernames must be a single word of letters, numbers or underscores")
+	}
+	p.Username = username
+	p.Spectate = len(gameMsg.Fields["spectate"]) > 0
+	return nil
+}
+
+// A question a player wrote for the next round.
+type questionPayload struct {
+	Question string
+}
+
+func (p *questionPayload) parse(gameMsg *GameMessage) error {
+	question, err := validateQuestion(gameMsg.Msg)
+	if err != nil {
+		return err
+	}
+	p.Question = question
+	return nil
+}
+
+// A prompt a player wrote, either to paint a picture or as a guess at another player's prompt.
+type promptPayload struct {
+	Prompt string
+}
+
+func (p *promptPayload) parse(gameMsg *GameMessage) error {
+	prompt := strings.TrimSpace(gameMsg.Msg)
+	if prompt == "" {
+		return errors.New("Please write a prompt")
+	}
+	if utf8.RuneCountInString(prompt) > maxPromptLength {
+		return fmt.Errorf("Prompts can be at most %d characters", maxPromptLength)
+	}
+	p.Prompt = prompt
+	return nil
+}
+
+// The candidate a player picked, such as a question or their team's picture.
+type choicePayload struct {
+	CandidateID string
+}
+
+func (p *choicePayload) parse(gameMsg *GameMessage) error {
+	if gameMsg.Msg == "" {
+		return errors.New("Please make a choice")
+	}
+	if len(gameMsg.Msg) > maxIDLength {
+		return errors.New("Unknown choice")
+	}
+	p.CandidateID = gameMsg.Msg
+	return nil
+}
+
+// The picture a player picked to submit for the round.
+// Pictures are either served by the image generator or by the server itself.
+type picturePayload struct {
+	URL string
+}
+
+func (p *picturePayload) parse(gameMsg *GameMessage) error {
+	if len(gameMsg.Msg) > maxURLLength {
+		return errors.New("Unknown picture")
+	}
+	pictureURL, err := url.Parse(gameMsg.Msg)
+	if err != nil {
+		return errors.New("Unknown picture")
+	}
+	local := pictureURL.Scheme == "" && pictureURL.Host == "" && strings.HasPrefix(pictureURL.Path, "/pictures/")
+	if pictureURL.Scheme != "https" && !local {
+		return errors.New("Unknown picture")
+	}
+	p.URL = gameMsg.Msg
+	return nil
+}
+
+// The ballot a player cast on the voting page.
+type ballotPayload struct {
+	Ballot ballotForm
+}
+
+func (p *ballotPayload) parse(gameMsg *GameMessage) error {
+	if len(gameMsg.Msg) > maxIDLength {
+		return errors.New("Unknown choice")
+	}
+	err := checkFormFields(gameMsg.Fields)
+	if err != nil {
+		return err
+	}
+	p.Ballot = ballotForm{Choice: gameMsg.Msg, Fields: gameMsg.Fields}
+	return nil
+}
+
+// The host's settings form.
+type settingsPayload struct {
+	Fields map[string][]string
+}
+
+func (p *settingsPayload) parse(gameMsg *GameMessage) error {
+	err := checkFormFields(gameMsg.Fields)
+	if err != nil {
+		return err
+	}
+	p.Fields = gameMsg.Fields
+	return nil
+}
+
+// A chat message a player or spectator wrote.
+type chatPayload struct {
+	Text string
+}
+
+func (p *chatPayload) parse(gameMsg *GameMessage) error {
+	text := strings.TrimSpace(gameMsg.Msg)
+	if text == "" {
+		return errors.New("Please write a message")
+	}
+	if utf8.RuneCountInString(text) > maxChatLength {
+		return fmt.Errorf("Chat messages can be at most %d characters", maxChatLength)
+	}
+	p.Text = text
+	return nil
+}
+
+// A quick emoji reaction. Reactions to a picture on the voting page name its candidate.
+type reactionPayload struct {
+	Emoji       string
+	CandidateID string
+}
+
+func (p *reactionPayload) parse(gameMsg *GameMessage) error {
+	if !slices.Contains(reactionEmoji, gameMsg.Msg) {
+		return errors.New("Unknown reaction")
+	}
+	candidates := gameMsg.Fields["candidate"]
+	if len(candidates) > 1 || (len(candidates) == 1 && len(candidates[0]) > maxIDLength) {
+		return errors.New("Unknown choice")
+	}
+	p.Emoji = gameMsg.Msg
+	if len(candidates) == 1 {
+		p.CandidateID = candidates[0]
+	}
+	return nil
+}
+
+// Checks that a submitted form has no more inputs than any form on the site.
+// The values themselves are validated by whoever reads the form.
+func checkFormFields(fields map[string][]string) error {
+	count := 0
+	for name, values := range fields {
+		if len(name) > maxFieldLength {
+			return errors.New("The form has an unknown input")
+		}
+		for _, value := range values {
+			if len(value) > maxFieldLength {
+				return fmt.Errorf("Form inputs can be at most %d characters", maxFieldLength)
+			}
+		}
+		count += len(values)
+	}
+	if count > maxFormFields {
+		return errors.New("The form has too many inputs")
+	}
+	return nil
+}
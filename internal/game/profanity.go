@@ -0,0 +1,73 @@
+package game
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Fragments of offensive words. Text containing any of them is considered
+// offensive, which errs on the side of caution for generated text such as room codes.
+// Chat uses the narrower word lists below.
+var offensiveFragments = []string{
+	"anal", "anus", "arse", "ass", "bitch", "boob", "butt", "cock", "coon", "cum",
+	"damn", "dick", "dik", "dyke", "fag", "fuc", "fuk", "gay", "gook", "hell",
+	"homo", "jap", "jew", "jiz", "kike", "kill", "kok", "kum", "nazi", "negro",
+	"nig", "nob", "pedo", "penis", "pis", "poo", "porn", "puss", "puta", "puto",
+	"rape", "sex", "shit", "slut", "spic", "suck", "tit", "turd", "twat", "vag",
+	"wank", "whore",
+}
+
+// Reports whether the text contains an offensive word, ignoring case.
+func containsProfanity(text string) bool {
+	text = strings.ToLower(text)
+	for _, fragment := range offensiveFragments {
+		if strings.Contains(text, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Words blocked in chat. Unlike offensiveFragments, chat is filtered word by
+// word, so that innocent words such as "hello" or "class" get through.
+// Words are blocked if they start with a blocked stem or match a blocked word.
+var (
+	blockedChatStems = []string{
+		"fuck", "shit", "cunt", "nigg", "fagg", "whore", "bitch", "wank", "twat", "slut",
+	}
+	blockedChatWords = []string{
+		"ass", "asses", "asshole", "arse", "arsehole", "cock", "cocks", "dick", "dicks",
+		"dickhead", "fag", "fags", "kike", "kikes", "spic", "spics", "coon", "coons",
+		"gook", "gooks", "dyke", "dykes", "pussy", "retard", "retarded", "rape", "porn",
+		"tits", "jizz", "cum", "nazi", "nazis", "pedo",
+	}
+)
+
+// Reports whether a single word is blocked in chat, ignoring case and punctuation.
+func isBlockedChatWord(word string) bool {
+	word = strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}))
+	if word == "" {
+		return false
+	}
+	for _, stem := range blockedChatStems {
+		if strings.HasPrefix(word, stem) {
+			return true
+		}
+	}
+	return slices.Contains(blockedChatWords, word)
+}
+
+// Masks the blocked words of a chat message with asterisks.
+func censorChat(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		if isBlockedChatWord(word) {
+			words[i] = strings.Repeat("*", utf8.RuneCountInString(word))
+		}
+	}
+	return strings.Join(words, " ")
+}
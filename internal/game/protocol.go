@@ -0,0 +1,254 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// The WebSocket subprotocols a client can ask for when it connects.
+// Clients that don't ask for one are sent HTML for the HTMX frontend.
+const (
+	HTMLProtocol = "prompt-and-paint.html"    // HTML fragments swapped in by HTMX
+	JSONProtocol = "prompt-and-paint.v1.json" // Typed page data and events, for native and bot clients
+)
+
+// The subprotocols the server accepts, in order of preference.
+var Subprotocols = []string{JSONProtocol, HTMLProtocol}
+
+// The version of the JSON protocol. Clients may send it with their messages
+// to make sure the server speaks the protocol they were written for.
+const protocolVersion = 1
+
+// A message sent to JSON clients. Data is the page or event data the HTML
+// frontend renders, and Viewer describes who the page is being shown to, so
+// clients can work out their own view of the page as the HTML renderer does.
+type protocolMessage struct {
+	Version int          `json:"v"`
+	Event   gameEvent    `json:"event"`
+	Data    any          `json:"data,omitempty"`
+	Viewer  *viewerState `json:"viewer"`
+}
+
+// The client's identity and role, sent along with every JSON message.
+type viewerState struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username,omitempty"`
+	RoomID   string `json:"roomId,omitempty"`
+	Role     string `json:"role"`
+}
+
+// Returned when a client sends a message for a protocol version the server doesn't speak.
+var errUnsupportedVersion = errors.New("Unsupported protocol version")
+
+// Checks the protocol version a JSON client sent with its message.
+func checkProtocolVersion(data json.RawMessage) error {
+	var version int
+	err := json.Unmarshal(data, &version)
+	if err != nil || version != protocolVersion {
+		return fmt.Errorf("%w %s, the server speaks version %d", errUnsupportedVersion, data, protocolVersion)
+	}
+	return nil
+}
+
+// Tells the client that the server doesn't speak the protocol version of its message,
+// and which versions it does speak.
+func (c *Client) sendUnknownVersion(reason error) {
+	vd := &versionData{Message: reason.Error(), Supported: []int{protocolVersion}}
+	err := sendPage(c, unknownVersion, vd, generateVersionNotice)
+	if err != nil {
+		log.Printf("Error creating unknown version template: %v", err)
+	}
+}
+
+// Reports whether the client negotiated the JSON protocol.
+func (c *Client) usesJSON() bool {
+	return c.Protocol == JSONProtocol
+}
+
+// Describes the client for the messages sent to JSON clients.
+func (c *Client) viewer() *viewerState {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	vs := &viewerState{
+		UserID:   c.UserID,
+		Username: c.Username,
+		RoomID:   c.RoomID,
+		Role:     "player",
+	}
+	switch {
+	case c.Display:
+		vs.Role = "display"
+	case c.Spectator:
+		vs.Role = "spectator"
+	case c.Queued:
+		vs.Role = "queued"
+	}
+	return vs
+}
+
+// Encodes a message for a JSON client.
+func (c *Client) encodeJSON(event gameEvent, data any) ([]byte, error) {
+	return json.Marshal(&protocolMessage{
+		Version: protocolVersion,
+		Event:   event,
+		Data:    data,
+		Viewer:  c.viewer(),
+	})
+}
+
+// Sends a page to the client. JSON clients are sent the page's data and HTML
+// clients are sent the page created by generate.
+func sendPage[T any](c *Client, event gameEvent, data T, generate func(T) ([]byte, error)) error {
+	var page []byte
+	var err error
+	if c.usesJSON() {
+		page, err = c.encodeJSON(event, data)
+	} else {
+		page, err = generate(data)
+	}
+	if err != nil {
+		return err
+	}
+	c.write(page)
+	return nil
+}
+
+// Sends the client its view of a page published by the room. JSON clients
+// are sent the room's data for the page with other players' secrets removed.
+func (c *Client) sendView(event gameEvent, data string) error {
+	view, err := c.encodeView(event, data)
+	if err != nil {
+		return err
+	}
+	c.write(view)
+	return nil
+}
+
+// Encodes the client's view of a page published by the room.
+func (c *Client) encodeView(event gameEvent, data string) ([]byte, error) {
+	if c.usesJSON() {
+		view, err := c.publicView(event, data)
+		if err != nil {
+			return nil, err
+		}
+		return c.encodeJSON(event, view)
+	}
+	return c.renderView(event, data)
+}
+
+// A candidate as sent to JSON clients. Who wrote it and whether it is the real
+// prompt stay on the server, so votes stay anonymous. Own marks the viewer's candidate.
+type publicCandidate struct {
+	ID     string
+	Number int
+	URL    string
+	Text   string
+	Own    bool
+}
+
+// Converts candidates into the candidates the viewer may see.
+func publicCandidates(candidates []Candidate, userID string) []publicCandidate {
+	public := make([]publicCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		public = append(public, publicCandidate{
+			ID:     candidate.ID,
+			Number: candidate.Number,
+			URL:    candidate.URL,
+			Text:   candidate.Text,
+			Own:    candidate.AuthorID == userID,
+		})
+	}
+	return public
+}
+
+// The question page as sent to JSON clients. Candidates replaces the room's candidates.
+type publicQuestionPage struct {
+	*questionPageData
+	Candidates []publicCandidate
+}
+
+// The voting page as sent to JSON clients. Candidates replaces the room's candidates
+// and OwnCandidates only lists the pictures the viewer's team submitted.
+type publicVotingPage struct {
+	*votingPageData
+	Candidates    []publicCandidate
+	OwnCandidates []string
+}
+
+// The viewer's team on the huddle page, as sent to JSON clients.
+type publicHuddleTeam struct {
+	team
+	Candidates []publicCandidate
+}
+
+// The huddle page as sent to JSON clients, with only the viewer's team.
+type publicHuddlePage struct {
+	Team *publicHuddleTeam
+}
+
+// The telephone page as sent to JSON clients, with only the viewer's assignment.
+type publicTelephonePage struct {
+	Round      int
+	Question   string
+	Constraint *constraint
+	Length     int
+	Assignment *assignment
+}
+
+// Builds the client's view of a page published by the room for the JSON protocol.
+// Pages that hold other players' secrets, such as who submitted each candidate or
+// the pictures other players are shown, are trimmed to what the client may see.
+// Other pages are sent as the room published them.
+func (c *Client) publicView(event gameEvent, data string) (any, error) {
+	switch event {
+	case askPage, questionVotePage:
+		qpd := &questionPageData{}
+		err := json.Unmarshal([]byte(data), qpd)
+		if err != nil {
+			return nil, err
+		}
+		return &publicQuestionPage{questionPageData: qpd, Candidates: publicCandidates(qpd.Candidates, c.UserID)}, nil
+	case votePage:
+		vpd := &votingPageData{}
+		err := json.Unmarshal([]byte(data), vpd)
+		if err != nil {
+			return nil, err
+		}
+		vpd.excludeOwnTeam(c.UserID)
+		return &publicVotingPage{
+			votingPageData: vpd,
+			Candidates:     publicCandidates(vpd.Candidates, c.UserID),
+			OwnCandidates:  vpd.OwnCandidates[c.UserID],
+		}, nil
+	case huddlePage:
+		hpd := &huddlePageData{}
+		err := json.Unmarshal([]byte(data), hpd)
+		if err != nil {
+			return nil, err
+		}
+		view := &publicHuddlePage{}
+		for _, ht := range hpd.Teams {
+			if ht.hasMember(c.UserID) {
+				view.Team = &publicHuddleTeam{team: ht.team, Candidates: publicCandidates(ht.Candidates, c.UserID)}
+			}
+		}
+		return view, nil
+	case telephonePage:
+		tpd := &telephonePageData{}
+		err := json.Unmarshal([]byte(data), tpd)
+		if err != nil {
+			return nil, err
+		}
+		return &publicTelephonePage{
+			Round:      tpd.Round,
+			Question:   tpd.Question,
+			Constraint: tpd.Constraint,
+			Length:     tpd.Length,
+			Assignment: tpd.Assignments[c.UserID],
+		}, nil
+	default:
+		return json.RawMessage(data), nil
+	}
+}
@@ -0,0 +1,255 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"unicode/utf8"
+)
+
+// A type that represents where the question for each round comes from.
+type questionSource string
+
+const (
+	aiQuestions     questionSource = "ai"           // OpenAI writes the question
+	randomQuestions questionSource = "players"      // Players write questions and one is drawn at random
+	votedQuestions  questionSource = "players-vote" // Players write questions and vote for one
+)
+
+// The question sources in the order they are offered in the room settings.
+var questionSources = []questionSource{aiQuestions, randomQuestions, votedQuestions}
+
+// The longest question a player may write.
+const maxQuestionLength = 200
+
+// Returns a short human readable explanation of the question source.
+func (s questionSource) description() string {
+	switch s {
+	case randomQuestions:
+		return "Players write questions, one is drawn at random"
+	case votedQuestions:
+		return "Players write questions and vote for one"
+	default:
+		return "Questions are written by AI"
+	}
+}
+
+// Reports whether s is a known question source.
+func (s questionSource) valid() bool {
+	for _, source := range questionSources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether players write the questions.
+func (s questionSource) playerWritten() bool {
+	return s == randomQuestions || s == votedQuestions
+}
+
+// Validates a question written by a player. Returns the trimmed question.
+func validateQuestion(question string) (string, error) {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return "", errors.New("Please write a question")
+	}
+	if utf8.RuneCountInString(question) > maxQuestionLength {
+		return "", fmt.Errorf("Questions can be at most %d characters", maxQuestionLength)
+	}
+	return question, nil
+}
+
+// Collects the questions written by players before a round and the votes for them.
+type questionPoll struct {
+	Questions  map[string]string
+	Candidates []Candidate
+	Votes      map[string]string
+}
+
+// Creates an empty question poll.
+func newQuestionPoll() *questionPoll {
+	return &questionPoll{
+		Questions: make(map[string]string),
+		Votes:     make(map[string]string),
+	}
+}
+
+// Records a player's question, replacing any earlier question.
+func (qp *questionPoll) addQuestion(userID, question string) {
+	qp.Questions[userID] = question
+}
+
+// Shuffles the questions into anonymized candidates for the question vote.
+func (qp *questionPoll) makeCandidates() []Candidate {
+	candidates := make([]Candidate, 0, len(qp.Questions))
+	for userID, question := range qp.Questions {
+		candidates = append(candidates, Candidate{AuthorID: userID, Text: question})
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	for i := range candidates {
+		candidates[i].ID = fmt.Sprintf("q%d", i)
+		candidates[i].Number = i + 1
+	}
+	qp.Candidates = candidates
+	return candidates
+}
+
+// Records a player's vote for a question. Players may not vote for their own question.
+func (qp *questionPoll) addVote(voterID, candidateID string) error {
+	for _, candidate := range qp.Candidates {
+		if candidate.ID != candidateID {
+			continue
+		}
+		if candidate.AuthorID == voterID {
+			return errors.New("You can't vote for your own question")
+		}
+		qp.Votes[voterID] = candidateID
+		return nil
+	}
+	return errors.New("Unknown question: " + candidateID)
+}
+
+// Picks the question for the round. Picks the most voted question, breaking
+// ties at random. Without votes every question is equally likely.
+// Reports false if no player wrote a question.
+func (qp *questionPoll) pick() (Candidate, bool) {
+	if len(qp.Candidates) == 0 {
+		qp.makeCandidates()
+	}
+	if len(qp.Candidates) == 0 {
+		return Candidate{}, false
+	}
+	counts := make(map[string]int, len(qp.Candidates))
+	for _, candidateID := range qp.Votes {
+		counts[candidateID]++
+	}
+	most := 0
+	var top []Candidate
+	for _, candidate := range qp.Candidates {
+		switch count := counts[candidate.ID]; {
+		case count > most:
+			most = count
+			top = []Candidate{candidate}
+		case count == most:
+			top = append(top, candidate)
+		}
+	}
+	return top[rand.Intn(len(top))], true
+}
+
+// Asks every player to write a question for the next round.
+func (r *Room) sendAskingPage() {
+	r.Mutex.Lock()
+	r.Poll = newQuestionPoll()
+	r.Mutex.Unlock()
+
+	r.updateRoomState(asking)
+	err := r.publishPage(askPage, &questionPageData{MaxLength: maxQuestionLength})
+	if err != nil {
+		log.Printf("Error publishing asking page: %v", err)
+	}
+}
+
+// Records a player's question for the next round.
+// Rejected questions are reported back to the player so they can try again.
+func (r *Room) handleQuestion(userID, question string) {
+	question, err := validateQuestion(question)
+	if err != nil {
+		r.sendNotice(userID, err.Error())
+		return
+	}
+
+	r.Mutex.Lock()
+	if r.State != asking || r.Poll == nil {
+		r.Mutex.Unlock()
+		log.Printf("Error room %s is not accepting questions", r.ID)
+		return
+	}
+	r.Poll.addQuestion(userID, question)
+	r.Mutex.Unlock()
+
+	err = r.incrReadyCount(userID)
+	if err != nil {
+		log.Printf("Error updating ready count: %v", err)
+		return
+	}
+	r.checkRoomState()
+}
+
+// Moves on once every player has written a question.
+// Players vote on the questions if the room is set up to, and there is more than one.
+func (r *Room) finishAsking() {
+	r.Mutex.RLock()
+	vote := r.Settings.Questions == votedQuestions && r.Poll != nil && len(r.Poll.Questions) > 1
+	r.Mutex.RUnlock()
+	if vote {
+		r.sendQuestionVotePage()
+		return
+	}
+	r.pickQuestion()
+}
+
+// Sends the written questions to all clients so players can vote for one.
+func (r *Room) sendQuestionVotePage() {
+	r.Mutex.Lock()
+	qpd := &questionPageData{
+		Voting:     true,
+		Candidates: r.Poll.makeCandidates(),
+		MaxLength:  maxQuestionLength,
+	}
+	r.Mutex.Unlock()
+
+	r.updateRoomState(choosing)
+	err := r.publishPage(questionVotePage, qpd)
+	if err != nil {
+		log.Printf("Error publishing question vote page: %v", err)
+	}
+}
+
+// Records a player's vote for the question of the next round.
+func (r *Room) handleQuestionVote(userID, candidateID string) {
+	r.Mutex.Lock()
+	if r.State != choosing || r.Poll == nil {
+		r.Mutex.Unlock()
+		log.Printf("Error room %s is not accepting question votes", r.ID)
+		return
+	}
+	err := r.Poll.addVote(userID, candidateID)
+	r.Mutex.Unlock()
+	if err != nil {
+		r.sendNotice(userID, err.Error())
+		return
+	}
+
+	err = r.incrReadyCount(userID)
+	if err != nil {
+		log.Printf("Error updating ready count: %v", err)
+		return
+	}
+	r.checkRoomState()
+}
+
+// Starts the round with the question picked from the poll, crediting its author.
+// Falls back to an AI question if no player wrote one.
+func (r *Room) pickQuestion() {
+	r.Mutex.Lock()
+	if r.Poll == nil {
+		r.Mutex.Unlock()
+		log.Printf("Error room %s has no questions to pick from", r.ID)
+		return
+	}
+	picked, ok := r.Poll.pick()
+	r.Poll = nil
+	r.Mutex.Unlock()
+	if !ok {
+		r.sendAIGamePage()
+		return
+	}
+	r.beginRound(picked.Text, picked.AuthorID)
+}
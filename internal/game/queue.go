@@ -0,0 +1,116 @@
+package game
+
+import (
+	"log"
+)
+
+// A player who joined mid-game and waits to be let in at the start of the next round.
+type queuedPlayer struct {
+	UserID   string
+	Username string
+}
+
+// Reports whether new players can join the game straight away.
+// Players who join once the game has started wait for the next round, so they
+// never hold up the ready check of a round they weren't dealt into.
+// Must be called with the room's mutex held.
+func (r *Room) acceptsPlayers() bool {
+	return r.State == waiting
+}
+
+// Holds a player who joined mid-game until the next round starts.
+// The player is sent the current page to watch in the meantime.
+func (r *Room) queuePlayer(userID, username string) {
+	r.Mutex.Lock()
+	found := false
+	for i := range r.Queue {
+		if r.Queue[i].UserID == userID {
+			r.Queue[i].Username = username
+			found = true
+		}
+	}
+	if !found {
+		r.Queue = append(r.Queue, queuedPlayer{UserID: userID, Username: username})
+	}
+	r.Mutex.Unlock()
+
+	r.sendDirect(userID, queued, userID)
+	err := r.publishPlayerList()
+	if err != nil {
+		log.Printf("Error publishing new player list: %v", err)
+	}
+}
+
+// Removes a player from the queue. Returns false if the player was not queued.
+func (r *Room) removeFromQueue(userID string) bool {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	for i, player := range r.Queue {
+		if player.UserID == userID {
+			r.Queue = append(r.Queue[:i], r.Queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Lets every queued player into the game, in the order they joined.
+// Called between rounds, before the next round is dealt.
+func (r *Room) promoteQueue() {
+	r.Mutex.Lock()
+	queue := r.Queue
+	r.Queue = nil
+	r.Mutex.Unlock()
+	if len(queue) == 0 {
+		return
+	}
+
+	for _, player := range queue {
+		r.connectUser(player.UserID, player.Username)
+		r.sendDirect(player.UserID, promoted, player.UserID)
+	}
+	err := r.publishPlayerList()
+	if err != nil {
+		log.Printf("Error publishing new player list: %v", err)
+	}
+}
+
+// Gets the usernames of the queued players, keyed by userID.
+// Must be called with the room's mutex held.
+func (r *Room) getQueuedPlayers() map[string]string {
+	players := make(map[string]string, len(r.Queue))
+	for _, player := range r.Queue {
+		players[player.UserID] = player.Username
+	}
+	return players
+}
+
+// Records whether the player is waiting for the next round.
+// Called from the pub/sub read loop, so later pages are rendered for the right role.
+func (c *Client) setQueued(isQueued bool) {
+	c.Mutex.Lock()
+	c.Queued = isQueued
+	c.Mutex.Unlock()
+}
+
+// Shows a player who joined mid-game the page the room is on until the next round starts.
+func (c *Client) waitForNextRound() {
+	err := c.backupClientData()
+	if err != nil {
+		log.Println(err)
+	}
+	err = c.restoreView()
+	if err != nil {
+		log.Printf("Error restoring room state: %v", err)
+		return
+	}
+	c.sendNotice("The game has already started. You'll join at the start of the next round!")
+}
+
+// Backs up that a queued player has joined the game.
+func (c *Client) joinGame() {
+	err := c.backupClientData()
+	if err != nil {
+		log.Println(err)
+	}
+}
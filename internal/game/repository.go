@@ -3,12 +3,21 @@ package game
 import "context"
 
 // A global room repository used to keep track of all current rooms.
+// Rooms are identified internally by their ID, and by a short code for players.
+// Public rooms are also listed in the lobby.
 type roomRepository struct {
-	roomList  string
-	roomIDKey string
+	roomList    string
+	roomIDKey   string
+	publicRooms string
+	roomCodes   string
 }
 
-var roomRepo = &roomRepository{roomList: string(roomList), roomIDKey: string(roomID)}
+var roomRepo = &roomRepository{
+	roomList:    string(roomList),
+	roomIDKey:   string(roomID),
+	publicRooms: string(publicRooms),
+	roomCodes:   string(roomCodes),
+}
 
 // Adds a roomID to the global room list.
 func (g *roomRepository) addRoom(ctx context.Context, roomID string) error {
@@ -0,0 +1,163 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// The longest guess a player may submit in reverse mode.
+const maxGuessLength = 200
+
+// Points for picking the real prompt in reverse mode.
+const truthPoints = 2
+
+// The picture a player chose along with the prompt that generated it.
+type submissionPayload struct {
+	URL    string `json:"url"`
+	Prompt string `json:"prompt"`
+}
+
+// Normalizes a prompt so that guesses can be compared with the real prompt.
+// Ignores case, punctuation and repeated whitespace.
+func normalizePrompt(prompt string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsPunct(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, prompt)
+	return strings.Join(strings.Fields(cleaned), " ")
+}
+
+// Validates a guess at the real prompt. Returns the trimmed guess.
+// Guesses that match the real prompt are rejected so the truth is not duplicated.
+func validateGuess(guess, truth string) (string, error) {
+	guess = strings.TrimSpace(guess)
+	if guess == "" {
+		return "", errors.New("Please write a guess")
+	}
+	if len(guess) > maxGuessLength {
+		return "", fmt.Errorf("Guesses can be at most %d characters", maxGuessLength)
+	}
+	if normalizePrompt(guess) == normalizePrompt(truth) {
+		return "", errors.New("Too close to the real prompt! Try a different guess")
+	}
+	return guess, nil
+}
+
+// Scores a reverse mode round.
+// Voters who find the real prompt earn points, as does the artist for each of them.
+// Players earn a point for every player fooled by their fake prompt.
+type reverseGuessRule struct{}
+
+func (reverseGuessRule) Name() string { return "reverse" }
+func (reverseGuessRule) Description() string {
+	return fmt.Sprintf("%d points for finding the real prompt, one per player fooled", truthPoints)
+}
+
+func (reverseGuessRule) Score(result *RoundResult) []PointAward {
+	awards := make([]PointAward, 0, 2*len(result.Ballots))
+	for voterID, ballot := range result.Ballots {
+		choice, ok := ballot.topChoice()
+		if !ok {
+			continue
+		}
+		candidate, ok := result.Candidates[choice]
+		if !ok {
+			continue
+		}
+		if candidate.Truth {
+			awards = append(awards,
+				PointAward{UserID: voterID, Points: truthPoints, Reason: "Found the real prompt"},
+				PointAward{UserID: result.ArtistID, Points: 1, Reason: "Your picture gave it away"},
+			)
+			continue
+		}
+		awards = append(awards, PointAward{
+			UserID: candidate.AuthorID,
+			Points: 1,
+			Reason: fmt.Sprintf("Fooled %s", result.Players[voterID]),
+		})
+	}
+	return awards
+}
+
+// Gets the artist of the current round.
+// Returns an empty string if there is no artist or the artist has left the room.
+// Must be called with the room's mutex held.
+func (r *Room) getActiveArtist() string {
+	if r.Round == nil || r.Round.ArtistID == "" {
+		return ""
+	}
+	if _, ok := r.Players[r.Round.ArtistID]; !ok {
+		return ""
+	}
+	return r.Round.ArtistID
+}
+
+// Sends the artist's picture to the players so they can guess the prompt.
+func (r *Room) sendGuessingPage() {
+	r.Mutex.RLock()
+	artistID := r.Round.ArtistID
+	gpd := &guessingPageData{
+		URL:        r.Round.Submissions[artistID].URL,
+		ArtistID:   artistID,
+		ArtistName: r.Players[artistID],
+	}
+	r.Mutex.RUnlock()
+
+	r.updateRoomState(guessing)
+	err := r.publishPage(guessPage, gpd)
+	if err != nil {
+		log.Printf("Error publishing guessing page: %v", err)
+	}
+}
+
+// Records a player's guess at the real prompt.
+// Rejected guesses are reported back to the player so they can guess again.
+func (r *Room) handleGuess(userID, guess string) {
+	r.Mutex.Lock()
+	if r.State != guessing || r.Round == nil {
+		r.Mutex.Unlock()
+		log.Printf("Error room %s is not accepting guesses", r.ID)
+		return
+	}
+	if !r.isRequired(userID) {
+		r.Mutex.Unlock()
+		r.sendNotice(userID, "The artist does not guess their own prompt")
+		return
+	}
+	truth := r.Round.Submissions[r.Round.ArtistID].Prompt
+	guess, err := validateGuess(guess, truth)
+	if err != nil {
+		r.Mutex.Unlock()
+		r.sendNotice(userID, err.Error())
+		return
+	}
+	r.Round.Guesses[userID] = guess
+	r.Mutex.Unlock()
+
+	err = r.incrReadyCount(userID)
+	if err != nil {
+		log.Printf("Error updating ready count: %v", err)
+		return
+	}
+	r.checkRoomState()
+}
+
+// Parses the picture submitted by a player.
+func parseSubmission(msg string) (*submissionPayload, error) {
+	sp := &submissionPayload{}
+	err := json.Unmarshal([]byte(msg), sp)
+	if err != nil {
+		return nil, err
+	}
+	if sp.URL == "" {
+		return nil, errors.New("Missing picture URL")
+	}
+	return sp, nil
+}
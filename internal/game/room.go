@@ -6,8 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math/rand"
-	"strconv"
 	"sync"
 
 	"github.com/lithammer/shortuuid"
@@ -18,61 +16,130 @@ import (
 type roomState string
 
 const (
-	waiting roomState = "waiting"
-	playing roomState = "playing"
-	voting  roomState = "voting"
-	scoring roomState = "scoring"
+	waiting  roomState = "waiting"
+	asking   roomState = "asking"
+	choosing roomState = "choosing"
+	playing  roomState = "playing"
+	drawing  roomState = "drawing"
+	huddling roomState = "huddling"
+	relaying roomState = "relaying"
+	guessing roomState = "guessing"
+	voting   roomState = "voting"
+	scoring  roomState = "scoring"
 )
 
 // Represents a room of players, which conducts a match.
 // Used to store data for the match and synchronize the game events for the players.
-// Uniquely identified by RoomID.
+// Uniquely identified by RoomID. Players join with the shorter Code instead.
+// Rooms created by an integration are owned by it, so it can close them without playing.
 // Communicates with players over a pub/sub channel.
 type Room struct {
 	ID             string
+	Code           string
+	Host           string
+	Owner          string
 	Players        map[string]string
 	PlayerStatuses map[string]bool
+	Spectators     map[string]string
+	Displays       map[string]bool
+	Queue          []queuedPlayer
+	TurnOrder      []string
+	LastTurn       string
 	State          roomState
 	ReadyCount     int
+	PrevRanks      map[string]int
+	Teams          map[string]int
+	Captains       []string
+	PrevTeamRanks  map[string]int
+	Winners        []roundWinner
+	Standings      []leaderboardEntry
+	Settings       roomSettings
+	Round          *round
+	Poll           *questionPoll
+	Chains         []*chain
+	ChainOrder     []string
+	ChainLength    int
+	Assignments    map[string][]int
 	Pubsub         *redis.PubSub
 	Mutex          *sync.RWMutex
 	Ctx            context.Context
 	Cancel         context.CancelFunc
 }
 
-// Creates a brand new room.
-func createRoom() (*Room, error) {
+// Creates a brand new room hosted by the user with id hostID.
+func createRoom(hostID string) (*Room, error) {
+	room := newRoom(hostID)
+	return room, room.open()
+}
+
+// Builds a room hosted by the user with id hostID, without opening it.
+func newRoom(hostID string) *Room {
 	ctx, cancel := context.WithCancel(context.Background())
-	room := &Room{
+	return &Room{
 		ID:             shortuuid.New(),
+		Host:           hostID,
 		Players:        make(map[string]string),
 		PlayerStatuses: make(map[string]bool),
+		Spectators:     make(map[string]string),
+		Displays:       make(map[string]bool),
 		State:          waiting,
 		ReadyCount:     0,
+		PrevRanks:      make(map[string]int),
+		Teams:          make(map[string]int),
+		PrevTeamRanks:  make(map[string]int),
+		Settings:       defaultRoomSettings(),
 		Mutex:          &sync.RWMutex{},
 		Ctx:            ctx,
 		Cancel:         cancel,
 	}
-	err := roomRepo.addRoom(ctx, room.ID)
+}
+
+// Gives the room a code, adds it to the room list and starts reading its pub/sub channel.
+func (r *Room) open() error {
+	code, err := roomRepo.reserveCode(r.Ctx, r.ID)
+	if err != nil {
+		log.Printf("Error reserving room code: %v", err)
+		return err
+	}
+	r.Code = code
+	err = roomRepo.addRoom(r.Ctx, r.ID)
 	if err != nil {
 		log.Printf("Error adding room to room list: %v", err)
-		return nil, err
+		releaseErr := roomRepo.releaseCode(r.Ctx, r.Code, r.ID)
+		if releaseErr != nil {
+			log.Printf("Error releasing room code: %v", releaseErr)
+		}
+		return err
+	}
+	err = r.backupCode()
+	if err != nil {
+		log.Printf("Error backing up room code: %v", err)
+	}
+	r.resetReadyCount()
+	err = r.backupSettings()
+	if err != nil {
+		log.Printf("Error backing up room settings: %v", err)
 	}
-	room.resetReadyCount()
+	r.refreshListing()
 	go func() {
-		_, err := room.generateQuestion()
+		_, err := r.generateQuestion()
 		if err != nil {
 			log.Printf("Error generating question: %v", err)
 		}
 	}()
-	subscribeRoom(room)
-	return room, nil
+	subscribeRoom(r)
+	r.Mutex.RLock()
+	status := r.getStatus()
+	r.Mutex.RUnlock()
+	r.emitLifecycleEvent(&LifecycleEvent{Type: RoomCreated, Room: status})
+	return nil
 }
 
-// Deletes the room. Used when all players have left the room.
+// Deletes the room. Used when all players have left the room or the host closed it.
 // Deletes all data associated with the room in the database and stops the
 // pub/sub read loop.
 func (r *Room) deleteRoom() {
+	r.finishMatch()
 	err := deleteRedisKey(r.Ctx, r.ID)
 	if err != nil {
 		log.Printf("Error deleting room backup: %v", err)
@@ -81,6 +148,26 @@ func (r *Room) deleteRoom() {
 	if err != nil {
 		log.Printf("Error deleting leaderboard: %v", err)
 	}
+	err = deleteRedisKey(r.Ctx, r.getTeamLeaderboardKey())
+	if err != nil {
+		log.Printf("Error deleting team leaderboard: %v", err)
+	}
+	err = deleteRedisKey(r.Ctx, r.getRoundHistoryKey())
+	if err != nil {
+		log.Printf("Error deleting round history: %v", err)
+	}
+	err = deleteRedisKey(r.Ctx, r.getChatKey())
+	if err != nil {
+		log.Printf("Error deleting chat history: %v", err)
+	}
+	err = roomRepo.unlistRoom(r.Ctx, r.ID)
+	if err != nil {
+		log.Printf("Error removing room from lobby: %v", err)
+	}
+	err = roomRepo.releaseCode(r.Ctx, r.Code, r.ID)
+	if err != nil {
+		log.Printf("Error releasing room code: %v", err)
+	}
 	err = roomRepo.deleteRoom(r.Ctx, r.ID)
 	if err != nil {
 		log.Printf("Error deleting room from roomList: %v", err)
@@ -96,6 +183,9 @@ func (r *Room) getPlayers() map[string]string {
 // Adds a new user to the room.
 func (r *Room) addPlayerToRoom(userID, username string) {
 	r.Mutex.Lock()
+	if _, ok := r.Players[userID]; !ok {
+		r.TurnOrder = append(r.TurnOrder, userID)
+	}
 	r.Players[userID] = username
 	r.PlayerStatuses[userID] = false
 	r.Mutex.Unlock()
@@ -106,6 +196,12 @@ func (r *Room) deletePlayerFromRoom(userID string) {
 	r.Mutex.Lock()
 	delete(r.Players, userID)
 	delete(r.PlayerStatuses, userID)
+	for i, player := range r.TurnOrder {
+		if player == userID {
+			r.TurnOrder = append(r.TurnOrder[:i], r.TurnOrder[i+1:]...)
+			break
+		}
+	}
 	r.Mutex.Unlock()
 
 	playerState, err := getRedisHash(r.Ctx, userID, string(ready))
@@ -124,22 +220,16 @@ func (r *Room) getLeaderboardKey() string {
 	return fmt.Sprintf("%s:%s", r.ID, leaderboard)
 }
 
-// Retrieves the leaderboard from the database.
-func (r *Room) getLeaderboard() (map[string]int, error) {
-	players := r.getPlayers()
-	lbWithIDs, err := getRedisSortedSetWithScores(r.Ctx, r.getLeaderboardKey())
+// Retrieves the ranked leaderboard from the database.
+// deltas maps userIDs to the points they gained in the latest round.
+func (r *Room) getLeaderboard(deltas map[string]int) ([]leaderboardEntry, error) {
+	members, err := getRedisSortedSetWithScores(r.Ctx, r.getLeaderboardKey())
 	if err != nil {
 		return nil, err
 	}
-	lb := make(map[string]int, len(lbWithIDs))
-	for userID := range lbWithIDs {
-		username, ok := players[userID]
-		if !ok {
-			log.Printf("Error unknown player on leaderboard: %s", userID)
-			continue
-		}
-		lb[username] = lbWithIDs[userID]
-	}
+	r.Mutex.RLock()
+	lb := rankLeaderboard(members, r.getPlayers(), deltas, r.PrevRanks)
+	r.Mutex.RUnlock()
 	return lb, nil
 }
 
@@ -174,6 +264,72 @@ func (r *Room) getReadyCount() int {
 	return r.ReadyCount
 }
 
+// Gets the judge of the current round.
+// Returns an empty string if there is no judge or the judge has left the room.
+// Must be called with the room's mutex held.
+func (r *Room) getActiveJudge() string {
+	if r.Round == nil || r.Round.JudgeID == "" {
+		return ""
+	}
+	if _, ok := r.Players[r.Round.JudgeID]; !ok {
+		return ""
+	}
+	return r.Round.JudgeID
+}
+
+// Reports whether the player has to act before the room can move on from its
+// current state. The judge does not paint, and only the judge votes.
+// Only the artist paints in reverse mode, and everyone else guesses and votes.
+// Only captains paint when teams submit through their captain, and teams only
+// huddle when they have more than one picture to pick from.
+// Must be called with the room's mutex held.
+func (r *Room) isRequired(userID string) bool {
+	if _, ok := r.Players[userID]; !ok {
+		return false
+	}
+	if r.teamsActive() {
+		team, onTeam := r.teamOf(userID)
+		switch r.State {
+		case playing:
+			return onTeam && (r.Settings.TeamSubmission == teamVotes || r.Captains[team] == userID)
+		case huddling:
+			return onTeam && len(r.Round.TeamCandidates[team]) > 1
+		}
+	}
+	if artist := r.getActiveArtist(); artist != "" {
+		switch r.State {
+		case drawing:
+			return userID == artist
+		case guessing, voting:
+			return userID != artist
+		}
+	}
+	judge := r.getActiveJudge()
+	switch {
+	case judge == "":
+		return true
+	case r.State == playing:
+		return userID != judge
+	case r.State == voting:
+		return userID == judge
+	default:
+		return true
+	}
+}
+
+// Gets the number of players who have to act before the room can move on.
+func (r *Room) getRequiredCount() int {
+	r.Mutex.RLock()
+	defer r.Mutex.RUnlock()
+	count := 0
+	for userID := range r.Players {
+		if r.isRequired(userID) {
+			count++
+		}
+	}
+	return count
+}
+
 // Increments the ready count if the userID has not already been marked as ready.
 func (r *Room) incrReadyCount(userID string) error {
 	status, err := getRedisHash(r.Ctx, userID, string(ready))
@@ -181,17 +337,37 @@ func (r *Room) incrReadyCount(userID string) error {
 		return err
 	} else if status != string(isReady) {
 		return errors.New("Player is not ready")
-	} else if r.PlayerStatuses[userID] {
-		return errors.New("Player is already marked as ready")
 	}
 
 	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	if !r.isRequired(userID) {
+		return errors.New("Player does not need to act right now")
+	} else if r.PlayerStatuses[userID] {
+		return errors.New("Player is already marked as ready")
+	}
 	r.ReadyCount++
 	r.PlayerStatuses[userID] = true
-	r.Mutex.Unlock()
 	return nil
 }
 
+// Picks the player whose turn is next, rotating through players in the order they joined.
+// Must be called with the room's mutex held.
+func (r *Room) nextTurn() string {
+	if len(r.TurnOrder) == 0 {
+		return ""
+	}
+	next := 0
+	for i, userID := range r.TurnOrder {
+		if userID == r.LastTurn {
+			next = (i + 1) % len(r.TurnOrder)
+			break
+		}
+	}
+	r.LastTurn = r.TurnOrder[next]
+	return r.LastTurn
+}
+
 // Resets the ready count back to zero.
 func (r *Room) resetReadyCount() {
 	r.Mutex.Lock()
@@ -219,8 +395,32 @@ func (r *Room) generateQuestion() (string, error) {
 
 // Backs up the room state to the database.
 // Used when a player reconnects to the room.
-func (r *Room) backupRoomState(template []byte) error {
-	return setRedisHash(r.Ctx, r.ID, string(roomBackup), template)
+func (r *Room) backupRoomState(page []byte) error {
+	return setRedisHash(r.Ctx, r.ID, string(roomBackup), page)
+}
+
+// Publishes the data for a page to all clients via the pub/sub channel.
+// Each client renders its own view of the page. The page is also backed up
+// so that reconnecting players can restore it.
+func (r *Room) publishPage(event gameEvent, data any) error {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	page, err := json.Marshal(newPSMessage(event, r.ID, string(dataJSON)))
+	if err != nil {
+		return err
+	}
+	err = r.backupRoomState(page)
+	if err != nil {
+		log.Printf("Error backing up room state: %v", err)
+	}
+	err = r.renewCode()
+	if err != nil {
+		log.Printf("Error renewing room code: %v", err)
+	}
+	r.refreshListing()
+	return publishRoomMessage(r, page)
 }
 
 // Updates the room state to match the current game event.
@@ -230,6 +430,127 @@ func (r *Room) updateRoomState(newState roomState) {
 	r.Mutex.Unlock()
 }
 
+// Gets the settings message describing the room's current settings and host.
+func (r *Room) getSettingsMessage() *settingsMessage {
+	r.Mutex.RLock()
+	defer r.Mutex.RUnlock()
+	return &settingsMessage{Host: r.Host, Settings: r.Settings}
+}
+
+// Backs up the room settings and host to the database.
+// Used when a player loads the waiting page.
+func (r *Room) backupSettings() error {
+	settingsJSON, err := json.Marshal(r.getSettingsMessage())
+	if err != nil {
+		return err
+	}
+	return setRedisHash(r.Ctx, r.ID, string(settingsBackup), settingsJSON)
+}
+
+// Backs up and publishes the room settings to all clients via the pub/sub channel.
+// Also updates the room's lobby listing, since the settings decide whether it is public.
+func (r *Room) publishSettings() {
+	err := r.backupSettings()
+	if err != nil {
+		log.Printf("Error backing up room settings: %v", err)
+	}
+	r.refreshListing()
+	settingsJSON, err := json.Marshal(r.getSettingsMessage())
+	if err != nil {
+		log.Printf("Error marshalling room settings: %v", err)
+		return
+	}
+	settingsMsg, err := json.Marshal(newPSMessage(newSettings, r.ID, string(settingsJSON)))
+	if err != nil {
+		log.Printf("Error marshalling room settings message: %v", err)
+		return
+	}
+	err = publishRoomMessage(r, settingsMsg)
+	if err != nil {
+		log.Printf("Error publishing room settings: %v", err)
+	}
+}
+
+// Applies the settings form submitted by userID.
+// Only the host may change settings, and only between rounds.
+func (r *Room) handleSettings(userID, fieldsJSON string) {
+	fields := make(map[string][]string)
+	err := json.Unmarshal([]byte(fieldsJSON), &fields)
+	if err != nil {
+		log.Printf("Error unmarshalling room settings: %v", err)
+		return
+	}
+
+	r.Mutex.Lock()
+	if userID != r.Host {
+		r.Mutex.Unlock()
+		log.Printf("Error player %s is not the host of room %s", userID, r.ID)
+		return
+	}
+	if r.State != waiting && r.State != scoring {
+		r.Mutex.Unlock()
+		log.Printf("Error room %s cannot change settings while %s", r.ID, r.State)
+		return
+	}
+	updated, err := r.Settings.update(fields)
+	if err != nil {
+		r.Mutex.Unlock()
+		r.sendNotice(userID, err.Error())
+		return
+	}
+	r.Settings = updated
+	r.Mutex.Unlock()
+
+	r.publishSettings()
+}
+
+// Picks a new host if the current host has left the room.
+func (r *Room) reassignHost() {
+	r.Mutex.Lock()
+	if _, ok := r.Players[r.Host]; ok || len(r.Players) == 0 {
+		r.Mutex.Unlock()
+		return
+	}
+	for userID := range r.Players {
+		r.Host = userID
+		break
+	}
+	r.Mutex.Unlock()
+	r.publishSettings()
+}
+
+// Starts a new round with the provided question, credited to authorID if a player wrote it.
+// Picks the next judge or artist if the room's game mode needs one.
+func (r *Room) startRound(question, authorID string) *round {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	number := 1
+	if r.Round != nil {
+		number = r.Round.Number + 1
+	}
+	r.Round = newRound(number, question)
+	r.Round.QuestionAuthorID = authorID
+	if deck, ok := lookupConstraintDeck(r.Settings.ConstraintDeck); ok {
+		r.Round.Constraint = deck.draw()
+	}
+	if r.Settings.EditRounds.editsRound(number) && r.Settings.GameMode != reverseMode &&
+		r.Settings.GameMode != telephoneMode {
+		if winner, ok := r.lastWinner(); ok {
+			r.Round.Base = &winner
+		}
+	}
+	r.assignTeams()
+	if len(r.Players) > 1 {
+		switch r.Settings.GameMode {
+		case judgeMode:
+			r.Round.JudgeID = r.nextTurn()
+		case reverseMode:
+			r.Round.ArtistID = r.nextTurn()
+		}
+	}
+	return r.Round
+}
+
 // Reads client events from the pub/sub channel. Dispatches the appropriate event handler.
 func (r *Room) readPump() {
 	defer r.Pubsub.Close()
@@ -251,14 +572,36 @@ func (r *Room) readPump() {
 				go r.addUser(psEvent.Sender, psEvent.Msg)
 			case reconnect:
 				go r.connectUser(psEvent.Sender, psEvent.Msg)
+			case newSpectator:
+				go r.addSpectator(psEvent.Sender, psEvent.Msg)
+			case newDisplay:
+				go r.addDisplay(psEvent.Sender)
 			case ready:
-				go r.handleReadySignal(psEvent.Msg)
+				go r.handleReadySignal(psEvent.Sender)
 			case getPicture:
-				go r.handleUserSubmission(psEvent.Sender)
+				go r.handleUserSubmission(psEvent.Sender, psEvent.Msg)
+			case guess:
+				go r.handleGuess(psEvent.Sender, psEvent.Msg)
+			case teamVote:
+				go r.handleTeamVote(psEvent.Sender, psEvent.Msg)
+			case question:
+				go r.handleQuestion(psEvent.Sender, psEvent.Msg)
+			case questionVote:
+				go r.handleQuestionVote(psEvent.Sender, psEvent.Msg)
 			case vote:
 				go r.handleVote(psEvent.Sender, psEvent.Msg)
+			case audienceVote:
+				go r.handleAudienceVote(psEvent.Sender, psEvent.Msg)
+			case updateSettings:
+				go r.handleSettings(psEvent.Sender, psEvent.Msg)
+			case chat:
+				go r.handleChat(psEvent.Sender, psEvent.Msg)
+			case react:
+				go r.handleReaction(psEvent.Sender, psEvent.Msg)
+			case closeRoom:
+				go r.closeRoom(psEvent.Sender)
 			case leave, CloseWS:
-				go r.disconnectUser(psEvent.Msg)
+				go r.disconnectUser(psEvent.Sender)
 			}
 		case <-r.Ctx.Done():
 			return
@@ -266,43 +609,82 @@ func (r *Room) readPump() {
 	}
 }
 
-// Checks if all players are ready. If so, triggers appropriate update function.
+// Checks if all required players are ready. If so, triggers appropriate update function.
 func (r *Room) checkRoomState() {
-	playerCount := r.getPlayerCount()
+	requiredCount := r.getRequiredCount()
 	readyCount := r.getReadyCount()
-	if readyCount == 0 || readyCount < playerCount {
+	if readyCount == 0 || readyCount < requiredCount {
 		return
 	}
 	r.resetReadyCount()
 
 	switch r.State {
 	case waiting, scoring:
+		r.promoteQueue()
 		r.sendGamePage()
+	case asking:
+		r.finishAsking()
+	case choosing:
+		r.pickQuestion()
 	case playing:
+		if r.needsHuddle() {
+			r.sendHuddlePage()
+		} else {
+			r.sendVotingPage()
+		}
+	case huddling:
+		r.finishHuddle()
+	case guessing:
 		r.sendVotingPage()
+	case drawing:
+		r.sendGuessingPage()
 	case voting:
 		r.countVotes()
 	}
 }
 
 // Updates the room's internal state with the provided user information.
+// Players who were not already in the room are announced to lifecycle listeners.
 func (r *Room) connectUser(userID, username string) {
 	err := r.addPlayerToLeaderboard(userID)
 	if err != nil {
 		log.Printf("Error adding user to leaderboard: %v", err)
 		return
 	}
+	r.Mutex.RLock()
+	_, rejoining := r.Players[userID]
+	r.Mutex.RUnlock()
 	r.addPlayerToRoom(userID, username)
+	if !rejoining {
+		r.emitLifecycleEvent(&LifecycleEvent{
+			Type:   PlayerJoined,
+			Player: &RoomMember{UserID: userID, Username: username},
+		})
+	}
 }
 
 // Connects user and publishes the updated list of players.
+// Users who join once the game has started are queued for the next round instead.
+// Players who join straight away are told so, in case they reconnected from the queue.
 func (r *Room) addUser(userID, username string) {
+	r.Mutex.RLock()
+	joining := !r.acceptsPlayers()
+	r.Mutex.RUnlock()
+	if joining {
+		r.queuePlayer(userID, username)
+		return
+	}
 	r.connectUser(userID, username)
-	players := r.getPlayers()
-	pld := &playerListData{Players: players}
-	playerListBytes, err := generatePlayerList(pld)
+	r.Mutex.RLock()
+	hostless := r.Host == ""
+	r.Mutex.RUnlock()
+	if hostless {
+		r.reassignHost()
+	}
+	r.sendDirect(userID, promoted, userID)
+	err := r.publishPlayerList()
 	if err != nil {
-		log.Printf("Error creating player list template: %v", err)
+		log.Printf("Error publishing new player list: %v", err)
 		r.deletePlayerFromRoom(userID)
 		err := r.deletePlayerFromLeaderboard(userID)
 		if err != nil {
@@ -310,26 +692,26 @@ func (r *Room) addUser(userID, username string) {
 		}
 		return
 	}
-	playerList, err := json.Marshal(newPSMessage(newPlayerList, r.ID, string(playerListBytes)))
+}
+
+// Publishes the list of players and spectators to all clients via the pub/sub channel.
+func (r *Room) publishPlayerList() error {
+	r.Mutex.RLock()
+	playerListJSON, err := json.Marshal(&playerListData{
+		Players:    r.getPlayers(),
+		Queued:     r.getQueuedPlayers(),
+		Spectators: r.Spectators,
+	})
+	r.Mutex.RUnlock()
 	if err != nil {
-		log.Printf("Error marshalling new player list: %v", err)
-		r.deletePlayerFromRoom(userID)
-		err := r.deletePlayerFromLeaderboard(userID)
-		if err != nil {
-			log.Printf("Error removing player from database: %v", err)
-		}
-		return
+		return err
 	}
-	err = publishRoomMessage(r, playerList)
+	playerList, err := json.Marshal(newPSMessage(newPlayerList, r.ID, string(playerListJSON)))
 	if err != nil {
-		log.Printf("Error publishing new player list: %v", err)
-		r.deletePlayerFromRoom(userID)
-		err := r.deletePlayerFromLeaderboard(userID)
-		if err != nil {
-			log.Printf("Error removing player from database: %v", err)
-		}
-		return
+		return err
 	}
+	r.refreshListing()
+	return publishRoomMessage(r, playerList)
 }
 
 // Handles a ready signal from a client.
@@ -343,8 +725,20 @@ func (r *Room) handleReadySignal(userID string) {
 	r.checkRoomState()
 }
 
-// Sends the HTML for the game page to all clients via the pub/sub channel.
+// Starts the next round. Players first write the question if the room is set up to.
 func (r *Room) sendGamePage() {
+	r.Mutex.RLock()
+	source := r.Settings.Questions
+	r.Mutex.RUnlock()
+	if source.playerWritten() {
+		r.sendAskingPage()
+		return
+	}
+	r.sendAIGamePage()
+}
+
+// Starts the next round with the question generated by OpenAI.
+func (r *Room) sendAIGamePage() {
 	question, err := r.getQuestion()
 	if err != nil {
 		question, err = r.generateQuestion()
@@ -353,180 +747,378 @@ func (r *Room) sendGamePage() {
 			return
 		}
 	}
-	gpd := &gamePageData{Question: question}
 	go func() {
 		_, err := r.generateQuestion()
 		if err != nil {
 			log.Printf("Error generating question: %v", err)
 		}
 	}()
-	gamePageBytes, err := generateGamePage(gpd)
+	r.beginRound(question, "")
+}
+
+// Starts a round with the provided question and sends the game page to all
+// clients via the pub/sub channel. Telephone rounds start passing chains instead.
+func (r *Room) beginRound(question, authorID string) {
+	rd := r.startRound(question, authorID)
+	r.emitLifecycleEvent(&LifecycleEvent{
+		Type: RoundStarted,
+		Round: &RoundRecord{
+			Number:           rd.Number,
+			Question:         rd.Question,
+			QuestionAuthorID: rd.QuestionAuthorID,
+			StartedAt:        rd.StartedAt,
+		},
+	})
+	err := r.backupConstraint(rd.Constraint)
 	if err != nil {
-		log.Printf("Error creating game page template: %v", err)
-		return
+		log.Printf("Error backing up round constraint: %v", err)
 	}
-	err = r.backupRoomState(gamePageBytes)
-	if err != nil {
-		log.Printf("Error backing up game page data: %v", err)
+	baseURL := ""
+	if rd.Base != nil {
+		baseURL = rd.Base.URL
 	}
-	gamePage, err := json.Marshal(newPSMessage(enterGame, r.ID, string(gamePageBytes)))
+	err = r.backupBasePicture(baseURL)
 	if err != nil {
-		log.Printf("Error marshalling game page: %v", err)
+		log.Printf("Error backing up base picture: %v", err)
+	}
+	r.Mutex.RLock()
+	telephone := r.Settings.GameMode == telephoneMode
+	r.Mutex.RUnlock()
+	if telephone {
+		r.startTelephone()
 		return
 	}
-	err = publishRoomMessage(r, gamePage)
+	gpd := &gamePageData{
+		Question:         question,
+		QuestionAuthorID: authorID,
+		Constraint:       rd.Constraint,
+		Base:             rd.Base,
+		JudgeID:          rd.JudgeID,
+		ArtistID:         rd.ArtistID,
+	}
+	r.Mutex.RLock()
+	gpd.Teams = r.getTeams()
+	gpd.TeamVoting = r.teamsActive() && r.Settings.TeamSubmission == teamVotes
+	gpd.QuestionAuthor = r.Players[authorID]
+	gpd.JudgeName = r.Players[rd.JudgeID]
+	gpd.ArtistName = r.Players[rd.ArtistID]
+	r.Mutex.RUnlock()
+	if rd.ArtistID != "" {
+		r.updateRoomState(drawing)
+	} else {
+		r.updateRoomState(playing)
+	}
+	err = r.publishPage(enterGame, gpd)
 	if err != nil {
 		log.Printf("Error publishing game page: %v", err)
-		return
 	}
-	r.updateRoomState(playing)
 }
 
 // Handles user disconnection.
 // Agnostic to whether the disconnection was user-initiated or unexpected.
 func (r *Room) disconnectUser(userID string) {
+	if r.removeSpectator(userID) || r.removeDisplay(userID) || r.removeFromQueue(userID) {
+		return
+	}
+	r.Mutex.RLock()
+	wasJudge := r.Round != nil && r.Round.JudgeID == userID && r.State == voting
+	wasArtist := r.Round != nil && r.Round.ArtistID == userID &&
+		(r.State == drawing || r.State == guessing)
+	var question, authorID string
+	if wasArtist {
+		question, authorID = r.Round.Question, r.Round.QuestionAuthorID
+	}
+	username, wasPlayer := r.Players[userID]
+	r.Mutex.RUnlock()
 	r.deletePlayerFromRoom(userID)
+	if wasPlayer {
+		r.emitLifecycleEvent(&LifecycleEvent{
+			Type:   PlayerLeft,
+			Player: &RoomMember{UserID: userID, Username: username},
+		})
+	}
 
 	if r.getPlayerCount() == 0 {
 		r.deleteRoom()
+		return
+	}
+	r.reassignHost()
+	r.refreshListing()
+	r.removeFromChains(userID)
+	if wasJudge {
+		r.replaceVotingPage()
+	}
+	if wasArtist {
+		r.resetReadyCount()
+		r.beginRound(question, authorID)
+		return
 	}
 	r.checkRoomState()
 }
 
-// Handles a user submitted picture by updating the ready count.
-func (r *Room) handleUserSubmission(userID string) {
-	err := r.incrReadyCount(userID)
+// Handles a user submitted picture by recording it and updating the ready count.
+// The prompt is checked against the round's constraint again, since a picture made
+// in an earlier round may be submitted.
+func (r *Room) handleUserSubmission(userID, submissionJSON string) {
+	submission, err := parseSubmission(submissionJSON)
 	if err != nil {
-		log.Printf("Error updating ready count: %v", err)
+		log.Printf("Error parsing submitted picture: %v", err)
 		return
 	}
-	r.checkRoomState()
-}
 
-// Sends the HTML for the voting page to all clients via the pub/sub channel.
-func (r *Room) sendVotingPage() {
-	answers := make([]string, 0, r.getPlayerCount())
-	players := r.getPlayers()
-	for player := range players {
-		ans, err := getRedisHash(r.Ctx, player, string(picture))
-		if err != nil {
-			log.Printf("Error fetching player answer: %v", err)
-			continue
-		}
-		answers = append(answers, ans)
+	r.Mutex.RLock()
+	relay := r.State == relaying
+	r.Mutex.RUnlock()
+	if relay {
+		r.handleLink(userID, submission)
+		return
 	}
-	rand.Shuffle(len(answers), func(i, j int) {
-		answers[i], answers[j] = answers[j], answers[i]
-	})
 
-	for _, ans := range answers {
-		err := setRedisKey(r.Ctx, ans, 0)
+	r.Mutex.Lock()
+	if (r.State != playing && r.State != drawing) || r.Round == nil {
+		r.Mutex.Unlock()
+		log.Printf("Error room %s is not accepting pictures", r.ID)
+		return
+	}
+	if !r.isRequired(userID) {
+		r.Mutex.Unlock()
+		if r.teamsActive() {
+			r.sendNotice(userID, "Only your team captain submits a picture this round")
+		} else {
+			r.sendNotice(userID, "You do not paint this round")
+		}
+		return
+	}
+	if r.Round.Constraint != nil {
+		err = r.Round.Constraint.check(submission.Prompt)
 		if err != nil {
-			log.Printf("Error initializing vote count: %v", err)
-			continue
+			r.Mutex.Unlock()
+			r.sendNotice(userID, err.Error())
+			return
 		}
 	}
+	r.Round.addSubmission(userID, submission.URL, submission.Prompt)
+	r.Mutex.Unlock()
 
-	apd := &votingPageData{URLs: answers}
-	votingPageBytes, err := generateVotingPage(apd)
+	err = r.incrReadyCount(userID)
 	if err != nil {
-		log.Printf("Error creating voting page template: %v", err)
+		log.Printf("Error updating ready count: %v", err)
 		return
 	}
-	err = r.backupRoomState(votingPageBytes)
+	r.checkRoomState()
+}
+
+// Sends the voting page to all clients via the pub/sub channel.
+func (r *Room) sendVotingPage() {
+	r.Mutex.Lock()
+	r.Round.makeCandidates()
+	r.Mutex.Unlock()
+
+	r.updateRoomState(voting)
+	err := r.publishPage(votePage, r.getVotingPageData())
+	if err != nil {
+		log.Printf("Error publishing voting page: %v", err)
+	}
+}
+
+// Builds the voting page for the current round.
+// Rounds with a judge always use a single vote.
+func (r *Room) getVotingPageData() *votingPageData {
+	r.Mutex.RLock()
+	defer r.Mutex.RUnlock()
+	vpd := &votingPageData{
+		Mode:       r.getVotingMode(),
+		Candidates: r.Round.Candidates,
+		Budget:     voteBudget,
+		JudgeID:    r.getActiveJudge(),
+		ArtistID:   r.Round.ArtistID,
+		Audience:   r.audienceVoting(),
+	}
+	if vpd.Mode == rankedVote {
+		vpd.OwnCandidates = r.getOwnTeamCandidates()
+	}
+	vpd.JudgeName = r.Players[vpd.JudgeID]
+	if vpd.ArtistID != "" {
+		vpd.ArtistName = r.Players[vpd.ArtistID]
+		vpd.PictureURL = r.Round.Submissions[vpd.ArtistID].URL
+	}
+	vpd.Ranks = rankList(len(vpd.Candidates))
+	return vpd
+}
+
+// Gets the voting mode for the current round.
+// Must be called with the room's mutex held.
+func (r *Room) getVotingMode() votingMode {
+	if r.getActiveJudge() != "" || r.Round.ArtistID != "" {
+		return singleVote
+	}
+	return r.Settings.VotingMode
+}
+
+// Republishes the voting page after the judge leaves, so everyone can vote instead.
+func (r *Room) replaceVotingPage() {
+	err := r.publishPage(votePage, r.getVotingPageData())
 	if err != nil {
-		log.Printf("Error backing up game page data: %v", err)
+		log.Printf("Error publishing voting page: %v", err)
 	}
-	votingPage, err := json.Marshal(newPSMessage(votePage, r.ID, string(votingPageBytes)))
+}
+
+// Validates and records a player's ballot.
+// Rejected ballots are reported back to the player so they can vote again.
+func (r *Room) handleVote(userID, ballotJSON string) {
+	form := &ballotForm{}
+	err := json.Unmarshal([]byte(ballotJSON), form)
 	if err != nil {
-		log.Printf("Error marshalling voting page data: %v", err)
+		log.Printf("Error unmarshalling ballot: %v", err)
+		return
+	}
+
+	r.Mutex.RLock()
+	if r.State != voting || r.Round == nil {
+		r.Mutex.RUnlock()
+		log.Printf("Error room %s is not accepting votes", r.ID)
+		return
+	}
+	if !r.isRequired(userID) {
+		r.Mutex.RUnlock()
+		r.sendNotice(userID, "You do not vote this round")
 		return
 	}
-	err = publishRoomMessage(r, votingPage)
+	ballot, err := r.checkBallot(userID, form)
+	r.Mutex.RUnlock()
 	if err != nil {
-		log.Printf("Error publishing voting page: %v", err)
+		r.sendNotice(userID, err.Error())
 		return
 	}
-	r.updateRoomState(voting)
-}
 
-// Records a player's vote.
-func (r *Room) handleVote(userID, voteURL string) {
-	err := r.incrReadyCount(userID)
+	err = r.incrReadyCount(userID)
 	if err != nil {
 		log.Printf("Error updating ready count: %v", err)
 		return
 	}
-	err = incrRedisKey(r.Ctx, voteURL)
+	r.Mutex.Lock()
+	r.Round.addBallot(userID, ballot)
+	r.Mutex.Unlock()
+	r.checkRoomState()
+}
+
+// Validates a player's ballot against the current round's candidates.
+// Ranked ballots only rank the pictures of the other teams when teams are on.
+// Must be called with the room's mutex held.
+func (r *Room) checkBallot(userID string, form *ballotForm) (Ballot, error) {
+	mode := r.getVotingMode()
+	candidates := r.Round.Candidates
+	if mode == rankedVote {
+		candidates = r.getRankableCandidates(userID)
+	}
+	ballot, err := parseBallot(mode, form, candidates)
 	if err != nil {
-		log.Printf("Error updating vote counts: %v", err)
+		return nil, err
+	}
+	if r.Round.ArtistID != "" && r.Round.votesForSelf(userID, ballot) {
+		return nil, errors.New("You can't vote for your own guess")
+	}
+	if r.votesForTeam(userID, ballot) {
+		return nil, errors.New("You can't vote for your own team's picture")
+	}
+	return ballot, nil
+}
+
+// Sends a notice to a single player via the pub/sub channel.
+func (r *Room) sendNotice(userID, message string) {
+	r.sendDirect(userID, notice, message)
+}
+
+// Sends a message meant for a single client via the pub/sub channel.
+func (r *Room) sendDirect(userID string, event gameEvent, msg string) {
+	directMsg := newPSMessage(event, r.ID, msg)
+	directMsg.Recipient = userID
+	directJSON, err := json.Marshal(directMsg)
+	if err != nil {
+		log.Printf("Error marshalling %s message: %v", event, err)
 		return
 	}
-	r.checkRoomState()
+	err = publishRoomMessage(r, directJSON)
+	if err != nil {
+		log.Printf("Error publishing %s message: %v", event, err)
+	}
 }
 
-// Counts all the votes for each picture and updates each player's total score.
+// Scores the round with the room's scoring rules and updates each player's total score.
 func (r *Room) countVotes() {
-	scores := make(map[string]int)
-	players := r.getPlayers()
-	for player, username := range players {
-		url, err := getRedisHash(r.Ctx, player, string(picture))
-		if err != nil {
-			log.Printf("Error fetching player answer: %v", err)
-			continue
-		}
-		countString, err := getRedisKey(r.Ctx, url)
-		if err != nil {
-			log.Printf("Error retrieving vote count: %v", err)
-			continue
-		}
-		count, err := strconv.Atoi(countString)
-		if err != nil {
-			log.Printf("Error processing count: %v", err)
-			continue
-		}
-		scores[username] = count
+	r.Mutex.RLock()
+	result := r.Round.result(r.getPlayers())
+	rules := r.Settings.scoringRules()
+	if r.getActiveJudge() != "" {
+		rules = []ScoringRule{judgePickRule{}}
+	}
+	lpd := &leaderboardPageData{
+		Question:       r.Round.Question,
+		QuestionAuthor: r.Players[r.Round.QuestionAuthorID],
+		Constraint:     r.Round.Constraint,
 	}
+	if r.Round.ArtistID != "" {
+		rules = []ScoringRule{reverseGuessRule{}}
+		lpd.Answer = r.Round.Submissions[r.Round.ArtistID].Prompt
+		lpd.AnswerURL = r.Round.Submissions[r.Round.ArtistID].URL
+	}
+	if r.audienceVoting() {
+		rules = append(rules, audienceVoteRule{Bonus: r.Settings.AudienceBonus})
+	}
+	r.Mutex.RUnlock()
 
-	for player, username := range players {
-		err := r.updatePlayerScore(player, scores[username])
+	scores, breakdowns := scoreRound(rules, result)
+	record := r.recordRound(result, scores)
+	for player, score := range scores {
+		err := r.updatePlayerScore(player, score)
 		if err != nil {
 			log.Printf("Error updating player score: %v", err)
 		}
 	}
 
-	lb, err := r.getLeaderboard()
+	lb, err := r.getLeaderboard(scores)
 	if err != nil {
 		log.Printf("Error retrieving leaderboard: %v", err)
 		return
 	}
 
-	r.sendLeaderboard(scores, lb)
-}
-
-// Sends the HTML for the leaderboard page to all clients via the pub/sub channel.
-func (r *Room) sendLeaderboard(scores map[string]int, lb map[string]int) {
-	lpd := &leaderboardPageData{Scores: scores, Leaderboard: lb}
-	leaderboardPageBytes, err := generateLeaderboardPage(lpd)
-	if err != nil {
-		log.Printf("Error creating leaderboard page template: %v", err)
-		return
+	r.Mutex.Lock()
+	if winner, ok := pickWinner(result); ok && result.ArtistID == "" {
+		r.Winners = append(r.Winners, winner)
+		lpd.Winner = &winner
 	}
-	err = r.backupRoomState(leaderboardPageBytes)
-	if err != nil {
-		log.Printf("Error backing up game page data: %v", err)
+	roundScores := rankLeaderboard(sortScores(scores), r.getPlayers(), scores, nil)
+	r.PrevRanks = leaderboardRanks(lb)
+	r.Standings = lb
+	r.Mutex.Unlock()
+	r.emitLifecycleEvent(&LifecycleEvent{
+		Type:      RoundFinished,
+		Round:     record,
+		Standings: publicLeaderboard(lb),
+	})
+	for i := range roundScores {
+		roundScores[i].Awards = breakdowns[roundScores[i].UserID]
 	}
-	leaderboardPage, err := json.Marshal(
-		newPSMessage(sendLeaderboard, r.ID, string(leaderboardPageBytes)),
-	)
-	if err != nil {
-		log.Printf("Error marshalling leaderboard page: %v", err)
-		return
+
+	lpd.Scores = roundScores
+	lpd.Leaderboard = lb
+	r.Mutex.RLock()
+	teams := r.teamsActive()
+	r.Mutex.RUnlock()
+	if teams {
+		lpd.TeamLeaderboard, err = r.getTeamLeaderboard(r.updateTeamScores(scores))
+		if err != nil {
+			log.Printf("Error retrieving team leaderboard: %v", err)
+		}
 	}
-	err = publishRoomMessage(r, leaderboardPage)
+	r.sendLeaderboard(lpd)
+}
+
+// Sends the leaderboard page to all clients via the pub/sub channel.
+func (r *Room) sendLeaderboard(lpd *leaderboardPageData) {
+	r.updateRoomState(scoring)
+	err := r.publishPage(sendLeaderboard, lpd)
 	if err != nil {
 		log.Printf("Error publishing leaderboard: %v", err)
-		return
 	}
-	r.updateRoomState(scoring)
 }
@@ -0,0 +1,210 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Tracks the submissions and votes of the round in progress.
+type round struct {
+	Number           int
+	Question         string
+	QuestionAuthorID string
+	Constraint       *constraint
+	Base             *roundWinner
+	StartedAt        time.Time
+	VotingStartedAt  time.Time
+	Submissions      map[string]Submission
+	Candidates       []Candidate
+	Ballots          map[string]Ballot
+	Guesses          map[string]string
+	TeamCandidates   map[int][]Candidate
+	TeamVotes        map[string]string
+	AudienceVotes    map[string]string
+	JudgeID          string
+	ArtistID         string
+}
+
+// Creates a new round for the provided question.
+func newRound(number int, question string) *round {
+	return &round{
+		Number:        number,
+		Question:      question,
+		StartedAt:     time.Now(),
+		Submissions:   make(map[string]Submission),
+		Ballots:       make(map[string]Ballot),
+		Guesses:       make(map[string]string),
+		TeamVotes:     make(map[string]string),
+		AudienceVotes: make(map[string]string),
+	}
+}
+
+// Records a player's chosen picture, replacing any earlier submission.
+func (rd *round) addSubmission(userID, url, prompt string) {
+	rd.Submissions[userID] = Submission{
+		UserID:      userID,
+		URL:         url,
+		Prompt:      prompt,
+		SubmittedAt: time.Now(),
+	}
+}
+
+// Shuffles the submissions into anonymized candidates for the voting page.
+// In reverse mode the candidates are the guesses mixed in with the real prompt.
+func (rd *round) makeCandidates() []Candidate {
+	candidates := make([]Candidate, 0, len(rd.Submissions)+len(rd.Guesses))
+	if rd.ArtistID != "" {
+		for userID, guess := range rd.Guesses {
+			candidates = append(candidates, Candidate{AuthorID: userID, Text: guess})
+		}
+		candidates = append(candidates, Candidate{
+			AuthorID: rd.ArtistID,
+			Text:     rd.Submissions[rd.ArtistID].Prompt,
+			Truth:    true,
+		})
+	} else {
+		for userID, submission := range rd.Submissions {
+			candidates = append(candidates, Candidate{AuthorID: userID, URL: submission.URL})
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	for i := range candidates {
+		candidates[i].ID = fmt.Sprintf("c%d", i)
+		candidates[i].Number = i + 1
+	}
+	rd.Candidates = candidates
+	rd.VotingStartedAt = time.Now()
+	return candidates
+}
+
+// Groups the submissions into candidates for each team's vote on its picture.
+// teams maps each player to their team.
+func (rd *round) makeTeamCandidates(teams map[string]int) {
+	rd.TeamCandidates = make(map[int][]Candidate)
+	for userID, submission := range rd.Submissions {
+		team, ok := teams[userID]
+		if !ok {
+			continue
+		}
+		rd.TeamCandidates[team] = append(rd.TeamCandidates[team], Candidate{AuthorID: userID, URL: submission.URL})
+	}
+	id := 0
+	for _, candidates := range rd.TeamCandidates {
+		rand.Shuffle(len(candidates), func(i, j int) {
+			candidates[i], candidates[j] = candidates[j], candidates[i]
+		})
+		for i := range candidates {
+			candidates[i].ID = fmt.Sprintf("t%d", id)
+			candidates[i].Number = i + 1
+			id++
+		}
+	}
+}
+
+// Records a player's vote for the picture their team submits.
+// Players may only vote for pictures painted by their own team.
+func (rd *round) addTeamVote(teams map[string]int, voterID, candidateID string) error {
+	team, ok := teams[voterID]
+	if !ok {
+		return errors.New("You are not on a team this round")
+	}
+	for _, candidate := range rd.TeamCandidates[team] {
+		if candidate.ID == candidateID {
+			rd.TeamVotes[voterID] = candidateID
+			return nil
+		}
+	}
+	return errors.New("Pick one of your team's pictures")
+}
+
+// Keeps only the picture each team voted for. Ties go to the picture submitted first.
+func (rd *round) keepTeamPicks() {
+	counts := make(map[string]int, len(rd.TeamVotes))
+	for _, candidateID := range rd.TeamVotes {
+		counts[candidateID]++
+	}
+	picks := make(map[string]bool, len(rd.TeamCandidates))
+	for _, candidates := range rd.TeamCandidates {
+		var best Candidate
+		for _, candidate := range candidates {
+			switch {
+			case best.ID == "",
+				counts[candidate.ID] > counts[best.ID],
+				counts[candidate.ID] == counts[best.ID] &&
+					rd.Submissions[candidate.AuthorID].SubmittedAt.Before(rd.Submissions[best.AuthorID].SubmittedAt):
+				best = candidate
+			}
+		}
+		picks[best.AuthorID] = true
+	}
+	for userID := range rd.Submissions {
+		if !picks[userID] {
+			delete(rd.Submissions, userID)
+		}
+	}
+}
+
+// Looks up a candidate on the voting page by its ID.
+func (rd *round) lookupCandidate(candidateID string) (Candidate, bool) {
+	for _, candidate := range rd.Candidates {
+		if candidate.ID == candidateID {
+			return candidate, true
+		}
+	}
+	return Candidate{}, false
+}
+
+// Reports whether the ballot gives points to a candidate written by the voter.
+func (rd *round) votesForSelf(voterID string, ballot Ballot) bool {
+	for candidateID := range ballot {
+		candidate, ok := rd.lookupCandidate(candidateID)
+		if ok && candidate.AuthorID == voterID {
+			return true
+		}
+	}
+	return false
+}
+
+// Records a player's ballot, replacing any earlier ballot.
+func (rd *round) addBallot(voterID string, ballot Ballot) {
+	rd.Ballots[voterID] = ballot
+}
+
+// Builds the result of the round that is passed to the scoring rules.
+func (rd *round) result(players map[string]string) *RoundResult {
+	result := &RoundResult{
+		Number:           rd.Number,
+		Question:         rd.Question,
+		Players:          make(map[string]string, len(players)),
+		Submissions:      make(map[string]Submission, len(rd.Submissions)),
+		Candidates:       make(map[string]Candidate, len(rd.Candidates)),
+		Ballots:          make(map[string]Ballot, len(rd.Ballots)),
+		AudienceVotes:    make(map[string]string, len(rd.AudienceVotes)),
+		QuestionAuthorID: rd.QuestionAuthorID,
+		JudgeID:          rd.JudgeID,
+		ArtistID:         rd.ArtistID,
+		StartedAt:        rd.StartedAt,
+		VotingStartedAt:  rd.VotingStartedAt,
+		EndedAt:          time.Now(),
+	}
+	for userID, username := range players {
+		result.Players[userID] = username
+	}
+	for userID, submission := range rd.Submissions {
+		result.Submissions[userID] = submission
+	}
+	for _, candidate := range rd.Candidates {
+		result.Candidates[candidate.ID] = candidate
+	}
+	for voterID, ballot := range rd.Ballots {
+		result.Ballots[voterID] = ballot
+	}
+	for spectatorID, candidateID := range rd.AudienceVotes {
+		result.AudienceVotes[spectatorID] = candidateID
+	}
+	return result
+}
@@ -0,0 +1,345 @@
+package game
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// A picture submitted by a player during a round, along with the prompt that generated it.
+type Submission struct {
+	UserID      string
+	URL         string
+	Prompt      string
+	SubmittedAt time.Time
+}
+
+// An anonymized entry on the voting page, either a picture or a prompt.
+// Voters only see the ID, the display Number and the URL or Text.
+// AuthorID and Truth (set on the real prompt in reverse mode) are used for scoring.
+type Candidate struct {
+	ID       string
+	Number   int
+	AuthorID string
+	URL      string
+	Text     string
+	Truth    bool
+}
+
+// A single player's vote, mapping candidate IDs to the points given to them.
+type Ballot map[string]int
+
+// Returns the candidate that received the most points on the ballot.
+// Ties are broken by candidate ID. Returns false for an empty ballot.
+func (b Ballot) topChoice() (string, bool) {
+	best, bestPoints := "", 0
+	for candidateID, points := range b {
+		if points > bestPoints || (points == bestPoints && candidateID < best) {
+			best, bestPoints = candidateID, points
+		}
+	}
+	return best, best != ""
+}
+
+// Holds everything that happened during a round. Passed to scoring rules.
+// QuestionAuthorID is only set when a player wrote the round's question.
+// AudienceVotes maps each spectator who voted to the candidate they picked.
+type RoundResult struct {
+	Number           int
+	Question         string
+	QuestionAuthorID string
+	Players          map[string]string
+	Submissions      map[string]Submission
+	Candidates       map[string]Candidate
+	Ballots          map[string]Ballot
+	AudienceVotes    map[string]string
+	JudgeID          string
+	ArtistID         string
+	StartedAt        time.Time
+	VotingStartedAt  time.Time
+	EndedAt          time.Time
+}
+
+// Sums the points given to each candidate across all ballots, keyed by author.
+func (rr *RoundResult) votesReceived() map[string]int {
+	votes := make(map[string]int, len(rr.Submissions))
+	for _, ballot := range rr.Ballots {
+		for candidateID, points := range ballot {
+			candidate, ok := rr.Candidates[candidateID]
+			if !ok {
+				continue
+			}
+			votes[candidate.AuthorID] += points
+		}
+	}
+	return votes
+}
+
+// Returns the author who received the most votes.
+// Returns false if nobody voted or if there is a tie for first place.
+func (rr *RoundResult) outrightWinner() (string, bool) {
+	winner, most, tied := "", 0, false
+	for authorID, votes := range rr.votesReceived() {
+		switch {
+		case votes > most:
+			winner, most, tied = authorID, votes, false
+		case votes == most:
+			tied = true
+		}
+	}
+	return winner, most > 0 && !tied
+}
+
+// Points awarded to a player by a scoring rule, along with the reason shown on
+// the results page.
+type PointAward struct {
+	UserID string
+	Points int
+	Reason string
+}
+
+// A rule that turns the result of a round into points.
+// Rules are selected per room by name and applied in the order they are registered.
+type ScoringRule interface {
+	// A unique identifier used in room settings.
+	Name() string
+	// A short human readable explanation shown in the room settings.
+	Description() string
+	// Awards points for the round. May award several (or negative) amounts per player.
+	Score(result *RoundResult) []PointAward
+}
+
+// Keeps track of every scoring rule that rooms can choose from.
+type scoringRegistry struct {
+	rules map[string]ScoringRule
+	order []string
+	mutex *sync.RWMutex
+}
+
+var scoringRules = &scoringRegistry{
+	rules: make(map[string]ScoringRule),
+	mutex: &sync.RWMutex{},
+}
+
+const defaultScoringRule = "votes"
+
+// Makes a scoring rule available to all rooms. Replaces any rule with the same name.
+func RegisterScoringRule(rule ScoringRule) {
+	scoringRules.mutex.Lock()
+	defer scoringRules.mutex.Unlock()
+	if _, exists := scoringRules.rules[rule.Name()]; !exists {
+		scoringRules.order = append(scoringRules.order, rule.Name())
+	}
+	scoringRules.rules[rule.Name()] = rule
+}
+
+// Looks up a scoring rule by name.
+func (sr *scoringRegistry) lookup(name string) (ScoringRule, bool) {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+	rule, ok := sr.rules[name]
+	return rule, ok
+}
+
+// Returns all registered rules in registration order.
+func (sr *scoringRegistry) all() []ScoringRule {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+	rules := make([]ScoringRule, 0, len(sr.order))
+	for _, name := range sr.order {
+		rules = append(rules, sr.rules[name])
+	}
+	return rules
+}
+
+// Resolves rule names to rules, keeping registration order and dropping unknown names.
+func (sr *scoringRegistry) resolve(names []string) []ScoringRule {
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = true
+	}
+	rules := make([]ScoringRule, 0, len(names))
+	for _, rule := range sr.all() {
+		if selected[rule.Name()] {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// Applies each rule to the round result.
+// Returns the round score and the list of awards for each player still in the room.
+func scoreRound(rules []ScoringRule, result *RoundResult) (map[string]int, map[string][]PointAward) {
+	scores := make(map[string]int, len(result.Players))
+	breakdowns := make(map[string][]PointAward, len(result.Players))
+	for userID := range result.Players {
+		scores[userID] = 0
+	}
+	for _, rule := range rules {
+		for _, award := range rule.Score(result) {
+			if _, ok := result.Players[award.UserID]; !ok || award.Points == 0 {
+				continue
+			}
+			scores[award.UserID] += award.Points
+			breakdowns[award.UserID] = append(breakdowns[award.UserID], award)
+		}
+	}
+	return scores, breakdowns
+}
+
+// Awards one point per vote received.
+type votesReceivedRule struct{}
+
+func (votesReceivedRule) Name() string        { return "votes" }
+func (votesReceivedRule) Description() string { return "One point per vote received" }
+
+func (votesReceivedRule) Score(result *RoundResult) []PointAward {
+	awards := make([]PointAward, 0, len(result.Submissions))
+	for authorID, votes := range result.votesReceived() {
+		awards = append(awards, PointAward{
+			UserID: authorID,
+			Points: votes,
+			Reason: fmt.Sprintf("%d vote(s) received", votes),
+		})
+	}
+	return awards
+}
+
+// Awards a bonus when every voter picked the same picture.
+type unanimousBonusRule struct{}
+
+const unanimousBonus = 3
+
+func (unanimousBonusRule) Name() string { return "unanimous" }
+func (unanimousBonusRule) Description() string {
+	return fmt.Sprintf("%d bonus points for a unanimous winner", unanimousBonus)
+}
+
+func (unanimousBonusRule) Score(result *RoundResult) []PointAward {
+	if len(result.Ballots) < 2 {
+		return nil
+	}
+	pick := ""
+	for _, ballot := range result.Ballots {
+		choice, ok := ballot.topChoice()
+		if !ok || (pick != "" && choice != pick) {
+			return nil
+		}
+		pick = choice
+	}
+	candidate, ok := result.Candidates[pick]
+	if !ok {
+		return nil
+	}
+	return []PointAward{{UserID: candidate.AuthorID, Points: unanimousBonus, Reason: "Unanimous winner"}}
+}
+
+// Awards a point to every voter whose top choice was the outright winner.
+type majorityVoterRule struct{}
+
+func (majorityVoterRule) Name() string        { return "majority" }
+func (majorityVoterRule) Description() string { return "One point for voting with the majority" }
+
+func (majorityVoterRule) Score(result *RoundResult) []PointAward {
+	winner, ok := result.outrightWinner()
+	if !ok {
+		return nil
+	}
+	awards := make([]PointAward, 0, len(result.Ballots))
+	for voterID, ballot := range result.Ballots {
+		choice, ok := ballot.topChoice()
+		if !ok || result.Candidates[choice].AuthorID != winner {
+			continue
+		}
+		awards = append(awards, PointAward{UserID: voterID, Points: 1, Reason: "Voted with the majority"})
+	}
+	return awards
+}
+
+// Takes a point away from every player whose picture received no votes.
+type zeroVotesPenaltyRule struct{}
+
+func (zeroVotesPenaltyRule) Name() string        { return "no-votes-penalty" }
+func (zeroVotesPenaltyRule) Description() string { return "Lose a point for receiving no votes" }
+
+func (zeroVotesPenaltyRule) Score(result *RoundResult) []PointAward {
+	votes := result.votesReceived()
+	awards := make([]PointAward, 0, len(result.Submissions))
+	for authorID := range result.Submissions {
+		if votes[authorID] > 0 {
+			continue
+		}
+		awards = append(awards, PointAward{UserID: authorID, Points: -1, Reason: "No votes received"})
+	}
+	return awards
+}
+
+// Awards points based on the share of the vote, Quiplash style.
+type percentageRule struct{}
+
+const percentageScale = 100
+
+func (percentageRule) Name() string { return "percentage" }
+func (percentageRule) Description() string {
+	return fmt.Sprintf("Up to %d points for your share of the vote", percentageScale)
+}
+
+func (percentageRule) Score(result *RoundResult) []PointAward {
+	votes := result.votesReceived()
+	total := 0
+	for _, count := range votes {
+		total += count
+	}
+	if total == 0 {
+		return nil
+	}
+	authors := make([]string, 0, len(votes))
+	for authorID := range votes {
+		authors = append(authors, authorID)
+	}
+	sort.Strings(authors)
+	awards := make([]PointAward, 0, len(authors))
+	for _, authorID := range authors {
+		share := float64(votes[authorID]) / float64(total)
+		awards = append(awards, PointAward{
+			UserID: authorID,
+			Points: int(math.Round(share * percentageScale)),
+			Reason: fmt.Sprintf("%.0f%% of the vote", share*100),
+		})
+	}
+	return awards
+}
+
+// Awards a point to the author of the picture the judge picked.
+// Used instead of the room's scoring rules for rounds with a judge.
+type judgePickRule struct{}
+
+func (judgePickRule) Name() string        { return "judge" }
+func (judgePickRule) Description() string { return "One point for the judge's pick" }
+
+func (judgePickRule) Score(result *RoundResult) []PointAward {
+	ballot, ok := result.Ballots[result.JudgeID]
+	if !ok {
+		return nil
+	}
+	choice, ok := ballot.topChoice()
+	if !ok {
+		return nil
+	}
+	candidate, ok := result.Candidates[choice]
+	if !ok {
+		return nil
+	}
+	return []PointAward{{UserID: candidate.AuthorID, Points: 1, Reason: "Picked by the judge"}}
+}
+
+// Registers the built-in scoring rules.
+func init() {
+	RegisterScoringRule(votesReceivedRule{})
+	RegisterScoringRule(unanimousBonusRule{})
+	RegisterScoringRule(majorityVoterRule{})
+	RegisterScoringRule(zeroVotesPenaltyRule{})
+	RegisterScoringRule(percentageRule{})
+}
@@ -0,0 +1,87 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Errors returned when a message posted to a fallback session can't be delivered.
+var (
+	ErrUnknownSession   = errors.New("Unknown session")
+	ErrMalformedMessage = errors.New("Malformed message")
+)
+
+// Gets the database key, which is also the pub/sub channel, of a fallback session.
+func sessionKey(sessionID string) string {
+	return fmt.Sprintf("%s:%s", clientSessions, sessionID)
+}
+
+// Opens a fallback session for a client that can't keep a WebSocket open.
+// The client's messages are posted over plain HTTP, possibly to another server,
+// and relayed to the session over pub/sub. Returns the session's id.
+// The session is closed along with the client.
+func (c *Client) OpenSession() (string, error) {
+	sessionID := shortuuid.New()
+	err := setRedisKey(c.Ctx, sessionKey(sessionID), c.UserID)
+	if err != nil {
+		return "", err
+	}
+	pubsub, err := subscribeChannel(c.Ctx, sessionKey(sessionID))
+	if err != nil {
+		return "", err
+	}
+	go c.readSession(sessionID, pubsub)
+	return sessionID, nil
+}
+
+// Dispatches the messages posted to the client's fallback session until the client disconnects.
+func (c *Client) readSession(sessionID string, pubsub *redis.PubSub) {
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+
+	for {
+		select {
+		case msg := <-ch:
+			err := setRedisKey(c.Ctx, sessionKey(sessionID), c.UserID)
+			if err != nil {
+				log.Printf("Error renewing session: %v", err)
+			}
+			ReceiveMessage(c, []byte(msg.Payload))
+		case <-c.Ctx.Done():
+			err := deleteRedisKey(context.Background(), sessionKey(sessionID))
+			if err != nil {
+				log.Printf("Error deleting session: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// Relays a message the user posted to their fallback session.
+// Messages to sessions that don't exist or belong to another user are refused,
+// as are messages that aren't JSON objects. Messages whose fields can't be decoded
+// are rejected on the session, as they are over a WebSocket. Disconnections are only
+// noticed by the server holding the session, so clients can't post them.
+func PostToSession(ctx context.Context, sessionID, userID string, msg []byte) error {
+	owner, err := getRedisKey(ctx, sessionKey(sessionID))
+	if err == redis.Nil || (err == nil && owner != userID) {
+		return ErrUnknownSession
+	} else if err != nil {
+		return err
+	}
+	var envelope struct {
+		Event gameEvent `json:"event"`
+	}
+	err = json.Unmarshal(msg, &envelope)
+	if err != nil || envelope.Event == CloseWS {
+		return ErrMalformedMessage
+	}
+	return publishChannelMessage(ctx, sessionKey(sessionID), msg)
+}
@@ -0,0 +1,168 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Holds the options the host can configure for a room.
+type roomSettings struct {
+	Name           string         `json:"name"`
+	Public         bool           `json:"public"`
+	GameMode       gameMode       `json:"gameMode"`
+	Questions      questionSource `json:"questions"`
+	ScoringRules   []string       `json:"scoringRules"`
+	VotingMode     votingMode     `json:"votingMode"`
+	Teams          int            `json:"teams"`
+	TeamSubmission teamSubmission `json:"teamSubmission"`
+	ConstraintDeck string         `json:"constraintDeck"`
+	EditRounds     editSchedule   `json:"editRounds"`
+	AudienceBonus  int            `json:"audienceBonus"`
+}
+
+// The settings every new room starts with.
+func defaultRoomSettings() roomSettings {
+	return roomSettings{
+		GameMode:       classicMode,
+		Questions:      aiQuestions,
+		ScoringRules:   []string{defaultScoringRule},
+		VotingMode:     singleVote,
+		TeamSubmission: captainSubmits,
+		ConstraintDeck: defaultConstraintDeck,
+		EditRounds:     noEdits,
+	}
+}
+
+// Builds new settings from the fields of the host's settings form.
+// Errors if the form selects an unknown option.
+func (s roomSettings) update(fields map[string][]string) (roomSettings, error) {
+	updated := s
+	name, err := singleField(fields, "name")
+	if err != nil {
+		return s, err
+	}
+	updated.Name, err = parseRoomName(name)
+	if err != nil {
+		return s, err
+	}
+	visibility, err := singleField(fields, "visibility")
+	if err != nil {
+		return s, err
+	}
+	updated.Public, err = parseVisibility(visibility)
+	if err != nil {
+		return s, err
+	}
+
+	gameModeName, err := singleField(fields, "mode")
+	if err != nil {
+		return s, err
+	}
+	if !gameMode(gameModeName).valid() {
+		return s, errors.New("Unknown game mode: " + gameModeName)
+	}
+	updated.GameMode = gameMode(gameModeName)
+
+	source, err := singleField(fields, "questions")
+	if err != nil {
+		return s, err
+	}
+	if !questionSource(source).valid() {
+		return s, errors.New("Unknown question source: " + source)
+	}
+	updated.Questions = questionSource(source)
+
+	deck, err := singleField(fields, "constraints")
+	if err != nil {
+		return s, err
+	}
+	if _, ok := lookupConstraintDeck(deck); !ok {
+		return s, errors.New("Unknown constraint deck: " + deck)
+	}
+	updated.ConstraintDeck = deck
+
+	schedule, err := singleField(fields, "edits")
+	if err != nil {
+		return s, err
+	}
+	if !editSchedule(schedule).valid() {
+		return s, errors.New("Unknown edit schedule: " + schedule)
+	}
+	updated.EditRounds = editSchedule(schedule)
+
+	rules := fields["scoring"]
+	if len(rules) == 0 {
+		return s, errors.New("At least one scoring rule must be selected")
+	}
+	for _, name := range rules {
+		if _, ok := scoringRules.lookup(name); !ok {
+			return s, errors.New("Unknown scoring rule: " + name)
+		}
+	}
+	updated.ScoringRules = rules
+
+	mode, err := singleField(fields, "voting")
+	if err != nil {
+		return s, err
+	}
+	if !votingMode(mode).valid() {
+		return s, errors.New("Unknown voting mode: " + mode)
+	}
+	updated.VotingMode = votingMode(mode)
+
+	teams, err := singleField(fields, "teams")
+	if err != nil {
+		return s, err
+	}
+	updated.Teams, err = parseTeamCount(teams)
+	if err != nil {
+		return s, err
+	}
+	submission, err := singleField(fields, "team-submission")
+	if err != nil {
+		return s, err
+	}
+	if !teamSubmission(submission).valid() {
+		return s, errors.New("Unknown team submission: " + submission)
+	}
+	updated.TeamSubmission = teamSubmission(submission)
+
+	bonus, err := singleField(fields, "audience")
+	if err != nil {
+		return s, err
+	}
+	updated.AudienceBonus, err = parseAudienceBonus(bonus)
+	if err != nil {
+		return s, err
+	}
+	err = validateTeamSettings(updated)
+	if err != nil {
+		return s, err
+	}
+	return updated, nil
+}
+
+// Resolves the selected scoring rules.
+func (s roomSettings) scoringRules() []ScoringRule {
+	rules := scoringRules.resolve(s.ScoringRules)
+	if len(rules) == 0 {
+		rules = scoringRules.resolve([]string{defaultScoringRule})
+	}
+	return rules
+}
+
+// The settings of a room along with its host, as relayed to clients.
+type settingsMessage struct {
+	Host     string       `json:"host"`
+	Settings roomSettings `json:"settings"`
+}
+
+// Parses a settings message from the pub/sub channel or the database.
+func parseSettingsMessage(msg string) (*settingsMessage, error) {
+	sm := &settingsMessage{}
+	err := json.Unmarshal([]byte(msg), sm)
+	if err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
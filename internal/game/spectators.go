@@ -0,0 +1,207 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// The bonuses the host can give to the audience's favorite pictures, in the
+// order they are offered in the room settings. Zero turns audience voting off.
+var audienceBonuses = []int{0, 3, 5, 10}
+
+// Parses the audience bonus chosen in the settings form.
+func parseAudienceBonus(value string) (int, error) {
+	bonus, err := strconv.Atoi(value)
+	if err == nil {
+		for _, allowed := range audienceBonuses {
+			if bonus == allowed {
+				return bonus, nil
+			}
+		}
+	}
+	return 0, errors.New("Unknown audience bonus: " + value)
+}
+
+// Returns a short human readable explanation of the audience bonus.
+func audienceBonusDescription(bonus int) string {
+	if bonus == 0 {
+		return "Spectators only watch"
+	}
+	return fmt.Sprintf("Spectators vote for up to %d bonus points", bonus)
+}
+
+// Awards bonus points for the share of the audience vote each picture received.
+// Not offered in the room settings, since it is applied whenever the room has an audience bonus.
+type audienceVoteRule struct {
+	Bonus int
+}
+
+func (audienceVoteRule) Name() string { return "audience" }
+func (rule audienceVoteRule) Description() string {
+	return audienceBonusDescription(rule.Bonus)
+}
+
+func (rule audienceVoteRule) Score(result *RoundResult) []PointAward {
+	votes := make(map[string]int, len(result.AudienceVotes))
+	for _, candidateID := range result.AudienceVotes {
+		candidate, ok := result.Candidates[candidateID]
+		if !ok {
+			continue
+		}
+		votes[candidate.AuthorID]++
+	}
+	total := 0
+	for _, count := range votes {
+		total += count
+	}
+	if total == 0 {
+		return nil
+	}
+	authors := make([]string, 0, len(votes))
+	for authorID := range votes {
+		authors = append(authors, authorID)
+	}
+	sort.Strings(authors)
+	awards := make([]PointAward, 0, len(authors))
+	for _, authorID := range authors {
+		share := float64(votes[authorID]) / float64(total)
+		awards = append(awards, PointAward{
+			UserID: authorID,
+			Points: int(math.Round(share * float64(rule.Bonus))),
+			Reason: fmt.Sprintf("%.0f%% of the audience vote", share*100),
+		})
+	}
+	return awards
+}
+
+// Adds a spectator to the room, who can watch every phase without holding up the game.
+// Also used when a spectator reconnects.
+func (r *Room) addSpectator(userID, username string) {
+	r.Mutex.Lock()
+	if _, ok := r.Players[userID]; ok {
+		r.Mutex.Unlock()
+		log.Printf("Error player %s cannot also spectate room %s", userID, r.ID)
+		return
+	}
+	r.Spectators[userID] = username
+	r.Mutex.Unlock()
+
+	err := r.publishPlayerList()
+	if err != nil {
+		log.Printf("Error publishing new player list: %v", err)
+	}
+}
+
+// Removes a spectator from the room. Returns false if the user was not spectating.
+func (r *Room) removeSpectator(userID string) bool {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	if _, ok := r.Spectators[userID]; !ok {
+		return false
+	}
+	delete(r.Spectators, userID)
+	return true
+}
+
+// Records a spectator's vote for their favorite picture.
+// Audience votes never count towards the ready count, so the room does not wait for them.
+func (r *Room) handleAudienceVote(userID, candidateID string) {
+	r.Mutex.Lock()
+	if _, ok := r.Spectators[userID]; !ok {
+		r.Mutex.Unlock()
+		log.Printf("Error user %s is not spectating room %s", userID, r.ID)
+		return
+	}
+	if r.State != voting || r.Round == nil || !r.audienceVoting() {
+		r.Mutex.Unlock()
+		r.sendNotice(userID, "The audience can't vote right now")
+		return
+	}
+	if _, ok := r.Round.lookupCandidate(candidateID); !ok {
+		r.Mutex.Unlock()
+		r.sendNotice(userID, "Pick one of the pictures")
+		return
+	}
+	r.Round.AudienceVotes[userID] = candidateID
+	r.Mutex.Unlock()
+}
+
+// Reports whether spectators vote in the current round.
+// Reverse rounds are not voted on by the audience, since they vote on guesses rather than pictures.
+// Must be called with the room's mutex held.
+func (r *Room) audienceVoting() bool {
+	return r.Settings.AudienceBonus > 0 && r.Round != nil && r.Round.ArtistID == ""
+}
+
+// Reports whether the client is watching rather than playing, either as a
+// spectator, as a display or while waiting to join the next round.
+func (c *Client) isWatching() bool {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+	return c.Spectator || c.Queued || c.Display
+}
+
+// The events a spectator or queued player may send. Anything else would act on the game.
+var watchingEvents = map[gameEvent]bool{
+	create:       true,
+	join:         true,
+	lobby:        true,
+	setUsername:  true,
+	audienceVote: true,
+	chat:         true,
+	react:        true,
+	leave:        true,
+	CloseWS:      true,
+}
+
+// Sends the spectator the page the room is currently on.
+// Spectators who join before the first round are sent to the waiting room.
+func (c *Client) startSpectating() {
+	spectatorMsg, err := json.Marshal(newPSMessage(newSpectator, c.UserID, c.Username))
+	if err != nil {
+		log.Printf("Error encoding new spectator message: %v", err)
+		return
+	}
+	err = publishClientMessage(c, spectatorMsg)
+	if err != nil {
+		log.Printf("Error publishing new spectator: %v", err)
+		return
+	}
+	err = c.backupClientData()
+	if err != nil {
+		log.Println(err)
+	}
+	err = c.restoreView()
+	if err == nil {
+		return
+	}
+	code, err := c.fetchRoomCode()
+	if err != nil {
+		log.Printf("Error fetching room code: %v", err)
+	}
+	wpd := &waitingPageData{RoomCode: code, Spectating: true}
+	err = sendPage(c, waitingPage, wpd, generateWaitingPage)
+	if err != nil {
+		log.Printf("Error creating waiting page template: %v", err)
+		return
+	}
+	c.sendSettingsPanel()
+}
+
+// Relays the spectator's vote for their favorite picture to the room.
+func (c *Client) handleAudienceVote(p *choicePayload) {
+	voteMsg, err := json.Marshal(newPSMessage(audienceVote, c.UserID, p.CandidateID))
+	if err != nil {
+		log.Printf("Error encoding audience vote: %v", err)
+		return
+	}
+	err = publishClientMessage(c, voteMsg)
+	if err != nil {
+		log.Printf("Error publishing audience vote: %v", err)
+	}
+}
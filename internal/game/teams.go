@@ -0,0 +1,381 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// A type that represents how a team settles on the one picture it submits.
+type teamSubmission string
+
+const (
+	captainSubmits teamSubmission = "captain" // The team captain submits for the team
+	teamVotes      teamSubmission = "vote"    // Every member paints and the team votes on a picture
+)
+
+// The ways teams submit in the order they are offered in the room settings.
+var teamSubmissions = []teamSubmission{captainSubmits, teamVotes}
+
+// The names of the teams, which also caps the number of teams in a room.
+var teamNames = []string{"Red", "Blue", "Green", "Yellow"}
+
+// Returns a short human readable explanation of how teams submit.
+func (s teamSubmission) description() string {
+	switch s {
+	case teamVotes:
+		return "Everyone paints, then each team votes on its picture"
+	default:
+		return "A rotating captain submits for the team"
+	}
+}
+
+// Reports whether s is a known way for teams to submit.
+func (s teamSubmission) valid() bool {
+	for _, submission := range teamSubmissions {
+		if s == submission {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses the number of teams chosen in the settings form. Zero turns teams off.
+func parseTeamCount(value string) (int, error) {
+	count, err := strconv.Atoi(value)
+	if err != nil || count == 1 || count < 0 || count > len(teamNames) {
+		return 0, fmt.Errorf("Pick between 2 and %d teams", len(teamNames))
+	}
+	return count, nil
+}
+
+// Gets the key a team is stored under on the team leaderboard.
+func teamKey(team int) string {
+	return fmt.Sprintf("team-%d", team)
+}
+
+// A team as shown to clients.
+type team struct {
+	Name      string
+	CaptainID string
+	Captain   string
+	MemberIDs []string
+	Members   []string
+}
+
+// Reports whether the player is on the team.
+func (t *team) hasMember(userID string) bool {
+	for _, memberID := range t.MemberIDs {
+		if memberID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Finds the team the player is on. Returns nil if the player is not on a team.
+func findTeam(teams []team, userID string) *team {
+	for i := range teams {
+		if teams[i].hasMember(userID) {
+			return &teams[i]
+		}
+	}
+	return nil
+}
+
+// Gets the key for the team leaderboard stored in the database.
+func (r *Room) getTeamLeaderboardKey() string {
+	return fmt.Sprintf("%s:%s", r.getLeaderboardKey(), "teams")
+}
+
+// Reports whether the current round is played in teams.
+// Must be called with the room's mutex held.
+func (r *Room) teamsActive() bool {
+	return len(r.Captains) > 0
+}
+
+// Gets the team the player is on for the current round.
+// Must be called with the room's mutex held.
+func (r *Room) teamOf(userID string) (int, bool) {
+	if !r.teamsActive() {
+		return 0, false
+	}
+	team, ok := r.Teams[userID]
+	return team, ok
+}
+
+// Splits the players into teams for the next round. Players keep their team
+// between rounds and new players join the smallest team. Teams are shuffled
+// from scratch (and the team leaderboard reset) when the number of teams changes.
+// Captains rotate through each team in the order players joined.
+// Must be called with the room's mutex held.
+func (r *Room) assignTeams() {
+	count := r.Settings.Teams
+	if count == 0 || r.Settings.GameMode != classicMode {
+		r.Captains = nil
+		return
+	}
+	if len(r.Captains) != count {
+		r.Teams = make(map[string]int, len(r.Players))
+		r.Captains = make([]string, count)
+		err := deleteRedisKey(r.Ctx, r.getTeamLeaderboardKey())
+		if err != nil {
+			log.Printf("Error resetting team leaderboard: %v", err)
+		}
+		r.PrevTeamRanks = make(map[string]int)
+	}
+	for userID := range r.Teams {
+		if _, ok := r.Players[userID]; !ok {
+			delete(r.Teams, userID)
+		}
+	}
+
+	sizes := make([]int, count)
+	for _, team := range r.Teams {
+		sizes[team]++
+	}
+	for _, userID := range r.TurnOrder {
+		if _, ok := r.Teams[userID]; ok {
+			continue
+		}
+		smallest := 0
+		for team := range sizes {
+			if sizes[team] < sizes[smallest] {
+				smallest = team
+			}
+		}
+		r.Teams[userID] = smallest
+		sizes[smallest]++
+	}
+
+	for team := range r.Captains {
+		r.Captains[team] = r.nextCaptain(team)
+		err := r.addTeamToLeaderboard(team)
+		if err != nil {
+			log.Printf("Error adding team to leaderboard: %v", err)
+		}
+	}
+}
+
+// Picks the member after the team's current captain, in the order players joined.
+// Must be called with the room's mutex held.
+func (r *Room) nextCaptain(team int) string {
+	members := make([]string, 0, len(r.TurnOrder))
+	for _, userID := range r.TurnOrder {
+		if t, ok := r.Teams[userID]; ok && t == team {
+			members = append(members, userID)
+		}
+	}
+	if len(members) == 0 {
+		return ""
+	}
+	for i, userID := range members {
+		if userID == r.Captains[team] {
+			return members[(i+1)%len(members)]
+		}
+	}
+	return members[0]
+}
+
+// Adds a team to the team leaderboard if it isn't already on it.
+func (r *Room) addTeamToLeaderboard(team int) error {
+	alreadyExists, err := checkMembershipRedisSortedSet(r.Ctx, r.getTeamLeaderboardKey(), teamKey(team))
+	if err != nil {
+		log.Printf("Error checking if team is already on leaderboard: %v", err)
+	} else if alreadyExists {
+		return nil
+	}
+	return addToRedisSortedSet(r.Ctx, r.getTeamLeaderboardKey(), teamKey(team))
+}
+
+// Gets the teams of the current round as shown to clients.
+// Must be called with the room's mutex held.
+func (r *Room) getTeams() []team {
+	if !r.teamsActive() {
+		return nil
+	}
+	teams := make([]team, len(r.Captains))
+	for i, captainID := range r.Captains {
+		teams[i] = team{Name: teamNames[i], CaptainID: captainID, Captain: r.Players[captainID]}
+	}
+	for _, userID := range r.TurnOrder {
+		if i, ok := r.Teams[userID]; ok {
+			teams[i].MemberIDs = append(teams[i].MemberIDs, userID)
+			teams[i].Members = append(teams[i].Members, r.Players[userID])
+		}
+	}
+	return teams
+}
+
+// Reports whether the ballot gives points to a picture submitted by the voter's team.
+// Must be called with the room's mutex held.
+func (r *Room) votesForTeam(voterID string, ballot Ballot) bool {
+	voterTeam, ok := r.teamOf(voterID)
+	if !ok {
+		return false
+	}
+	for candidateID := range ballot {
+		candidate, ok := r.Round.lookupCandidate(candidateID)
+		if !ok {
+			continue
+		}
+		if team, ok := r.teamOf(candidate.AuthorID); ok && team == voterTeam {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the candidates submitted by each player's team, which the player can't vote for.
+// Must be called with the room's mutex held.
+func (r *Room) getOwnTeamCandidates() map[string][]string {
+	if !r.teamsActive() {
+		return nil
+	}
+	teamCandidates := make(map[int][]string, len(r.Captains))
+	for _, candidate := range r.Round.Candidates {
+		if team, ok := r.teamOf(candidate.AuthorID); ok {
+			teamCandidates[team] = append(teamCandidates[team], candidate.ID)
+		}
+	}
+	own := make(map[string][]string, len(r.Teams))
+	for userID, team := range r.Teams {
+		if candidateIDs, ok := teamCandidates[team]; ok {
+			own[userID] = candidateIDs
+		}
+	}
+	return own
+}
+
+// Gets the candidates the voter ranks, leaving out their own team's pictures.
+// Otherwise a voter would have to rank their own team's picture whenever there are
+// no more candidates than places on the ballot.
+// Must be called with the room's mutex held.
+func (r *Room) getRankableCandidates(voterID string) []Candidate {
+	voterTeam, ok := r.teamOf(voterID)
+	if !ok {
+		return r.Round.Candidates
+	}
+	candidates := make([]Candidate, 0, len(r.Round.Candidates))
+	for _, candidate := range r.Round.Candidates {
+		if team, ok := r.teamOf(candidate.AuthorID); ok && team == voterTeam {
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// Reports whether teams have to vote on their pictures before the round's vote.
+func (r *Room) needsHuddle() bool {
+	r.Mutex.RLock()
+	defer r.Mutex.RUnlock()
+	return r.teamsActive() && r.Settings.TeamSubmission == teamVotes
+}
+
+// Sends each team its members' pictures so the team can vote on which to submit.
+func (r *Room) sendHuddlePage() {
+	r.Mutex.Lock()
+	r.Round.makeTeamCandidates(r.Teams)
+	hpd := &huddlePageData{}
+	for i, t := range r.getTeams() {
+		hpd.Teams = append(hpd.Teams, huddleTeam{team: t, Candidates: r.Round.TeamCandidates[i]})
+	}
+	r.State = huddling
+	needed := false
+	for userID := range r.Players {
+		needed = needed || r.isRequired(userID)
+	}
+	r.Mutex.Unlock()
+
+	if !needed {
+		r.finishHuddle()
+		return
+	}
+	err := r.publishPage(huddlePage, hpd)
+	if err != nil {
+		log.Printf("Error publishing huddle page: %v", err)
+	}
+}
+
+// Records a player's vote for the picture their team submits.
+func (r *Room) handleTeamVote(userID, candidateID string) {
+	r.Mutex.Lock()
+	if r.State != huddling || r.Round == nil {
+		r.Mutex.Unlock()
+		log.Printf("Error room %s is not accepting team votes", r.ID)
+		return
+	}
+	err := r.Round.addTeamVote(r.Teams, userID, candidateID)
+	r.Mutex.Unlock()
+	if err != nil {
+		r.sendNotice(userID, err.Error())
+		return
+	}
+
+	err = r.incrReadyCount(userID)
+	if err != nil {
+		log.Printf("Error updating ready count: %v", err)
+		return
+	}
+	r.checkRoomState()
+}
+
+// Keeps the picture each team voted for and moves on to the round's vote.
+func (r *Room) finishHuddle() {
+	r.Mutex.Lock()
+	r.Round.keepTeamPicks()
+	r.Mutex.Unlock()
+	r.sendVotingPage()
+}
+
+// Adds each team's points for the round to the team leaderboard.
+// A team earns every point its members earned.
+func (r *Room) updateTeamScores(scores map[string]int) map[string]int {
+	r.Mutex.RLock()
+	teamScores := make(map[string]int, len(r.Captains))
+	for team := range r.Captains {
+		teamScores[teamKey(team)] = 0
+	}
+	for userID, score := range scores {
+		if team, ok := r.teamOf(userID); ok {
+			teamScores[teamKey(team)] += score
+		}
+	}
+	r.Mutex.RUnlock()
+
+	for key, score := range teamScores {
+		err := updateRedisSortedSet(r.Ctx, r.getTeamLeaderboardKey(), key, score)
+		if err != nil {
+			log.Printf("Error updating team score: %v", err)
+		}
+	}
+	return teamScores
+}
+
+// Retrieves the ranked team leaderboard from the database.
+// deltas maps team keys to the points they gained in the latest round.
+func (r *Room) getTeamLeaderboard(deltas map[string]int) ([]leaderboardEntry, error) {
+	members, err := getRedisSortedSetWithScores(r.Ctx, r.getTeamLeaderboardKey())
+	if err != nil {
+		return nil, err
+	}
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+	names := make(map[string]string, len(r.Captains))
+	for team := range r.Captains {
+		names[teamKey(team)] = teamNames[team]
+	}
+	lb := rankLeaderboard(members, names, deltas, r.PrevTeamRanks)
+	r.PrevTeamRanks = leaderboardRanks(lb)
+	return lb, nil
+}
+
+// Validates the team options of the settings form.
+func validateTeamSettings(s roomSettings) error {
+	if s.Teams > 0 && s.GameMode != classicMode {
+		return errors.New("Teams can only play in classic mode")
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// One step of a telephone chain: the prompt a player wrote and the picture it made.
+type chainLink struct {
+	AuthorID    string
+	Author      string
+	Prompt      string
+	URL         string
+	SubmittedAt time.Time
+}
+
+// A telephone chain. Starts with the round's question and grows by one link
+// each time it is passed to the next player.
+type chain struct {
+	ID      int
+	OwnerID string
+	Owner   string
+	Links   []chainLink
+}
+
+// The chain a player is working on and what they are shown to continue it.
+// The first step of a chain shows the question, later steps show the previous
+// player's picture only.
+type assignment struct {
+	ChainID    int
+	Step       int
+	PictureURL string
+}
+
+// Identifies an assignment so clients only re-render when their assignment changes.
+func (a *assignment) key(roundNumber int) string {
+	if a == nil {
+		return fmt.Sprintf("%d:waiting", roundNumber)
+	}
+	return fmt.Sprintf("%d:%d:%d", roundNumber, a.ChainID, a.Step)
+}
+
+// Starts a chain for every player and assigns each player their own chain.
+// Chains are passed along in the order players joined.
+// Must be called with the room's mutex held.
+func (r *Room) startChains() {
+	r.ChainOrder = make([]string, 0, len(r.TurnOrder))
+	r.Chains = make([]*chain, 0, len(r.TurnOrder))
+	r.Assignments = make(map[string][]int, len(r.TurnOrder))
+	for _, userID := range r.TurnOrder {
+		if _, ok := r.Players[userID]; !ok {
+			continue
+		}
+		id := len(r.Chains)
+		r.ChainOrder = append(r.ChainOrder, userID)
+		r.Chains = append(r.Chains, &chain{ID: id, OwnerID: userID, Owner: r.Players[userID]})
+		r.Assignments[userID] = []int{id}
+	}
+	r.ChainLength = len(r.ChainOrder)
+}
+
+// Gets the player a chain is passed to after userID.
+// Must be called with the room's mutex held.
+func (r *Room) nextInChain(userID string) string {
+	for i, player := range r.ChainOrder {
+		if player == userID {
+			return r.ChainOrder[(i+1)%len(r.ChainOrder)]
+		}
+	}
+	return ""
+}
+
+// Gets the assignment at the front of the player's queue.
+// Returns nil if the player is waiting for a chain to be passed to them.
+// Must be called with the room's mutex held.
+func (r *Room) currentAssignment(userID string) *assignment {
+	queue := r.Assignments[userID]
+	if len(queue) == 0 {
+		return nil
+	}
+	ch := r.Chains[queue[0]]
+	a := &assignment{ChainID: ch.ID, Step: len(ch.Links) + 1}
+	if len(ch.Links) > 0 {
+		a.PictureURL = ch.Links[len(ch.Links)-1].URL
+	}
+	return a
+}
+
+// Reports whether every chain has been passed around the room.
+// Must be called with the room's mutex held.
+func (r *Room) chainsComplete() bool {
+	for _, ch := range r.Chains {
+		if len(ch.Links) < r.ChainLength {
+			return false
+		}
+	}
+	return true
+}
+
+// Builds the telephone page, which holds every player's current assignment.
+// Must be called with the room's mutex held.
+func (r *Room) getTelephonePageData() *telephonePageData {
+	tpd := &telephonePageData{
+		Round:       r.Round.Number,
+		Question:    r.Round.Question,
+		Constraint:  r.Round.Constraint,
+		Length:      r.ChainLength,
+		Assignments: make(map[string]*assignment, len(r.Assignments)),
+	}
+	for userID := range r.Assignments {
+		if a := r.currentAssignment(userID); a != nil {
+			tpd.Assignments[userID] = a
+		}
+	}
+	return tpd
+}
+
+// Starts passing chains around the room for the current round.
+func (r *Room) startTelephone() {
+	r.Mutex.Lock()
+	r.startChains()
+	r.State = relaying
+	r.Mutex.Unlock()
+	r.sendTelephonePage()
+}
+
+// Sends every player's current assignment to all clients via the pub/sub channel.
+func (r *Room) sendTelephonePage() {
+	r.Mutex.RLock()
+	tpd := r.getTelephonePageData()
+	r.Mutex.RUnlock()
+	err := r.publishPage(telephonePage, tpd)
+	if err != nil {
+		log.Printf("Error publishing telephone page: %v", err)
+	}
+}
+
+// Adds the player's picture to the chain at the front of their queue and
+// passes the chain on to the next player. Reveals the chains once they are all complete.
+func (r *Room) handleLink(userID string, submission *submissionPayload) {
+	r.Mutex.Lock()
+	if r.State != relaying {
+		r.Mutex.Unlock()
+		log.Printf("Error room %s is not passing chains", r.ID)
+		return
+	}
+	queue := r.Assignments[userID]
+	if len(queue) == 0 {
+		r.Mutex.Unlock()
+		r.sendNotice(userID, "Wait for a picture to be passed to you")
+		return
+	}
+	ch := r.Chains[queue[0]]
+	ch.Links = append(ch.Links, chainLink{
+		AuthorID:    userID,
+		Author:      r.Players[userID],
+		Prompt:      submission.Prompt,
+		URL:         submission.URL,
+		SubmittedAt: time.Now(),
+	})
+	r.Assignments[userID] = queue[1:]
+	if len(ch.Links) < r.ChainLength {
+		next := r.nextInChain(userID)
+		r.Assignments[next] = append(r.Assignments[next], ch.ID)
+	}
+	complete := r.chainsComplete()
+	r.Mutex.Unlock()
+
+	if complete {
+		r.sendChainReveal()
+		return
+	}
+	r.sendTelephonePage()
+}
+
+// Takes a player who left out of the chains. Their queued chains are passed on
+// to the next player, and chains are shortened to the number of players left.
+func (r *Room) removeFromChains(userID string) {
+	r.Mutex.Lock()
+	if r.State != relaying {
+		r.Mutex.Unlock()
+		return
+	}
+	next := r.nextInChain(userID)
+	queue := r.Assignments[userID]
+	delete(r.Assignments, userID)
+	for i, player := range r.ChainOrder {
+		if player == userID {
+			r.ChainOrder = append(r.ChainOrder[:i], r.ChainOrder[i+1:]...)
+			break
+		}
+	}
+	r.ChainLength = len(r.ChainOrder)
+	if next != "" && next != userID {
+		r.Assignments[next] = append(r.Assignments[next], queue...)
+	}
+	for player, queue := range r.Assignments {
+		pending := queue[:0]
+		for _, chainID := range queue {
+			if len(r.Chains[chainID].Links) < r.ChainLength {
+				pending = append(pending, chainID)
+			}
+		}
+		r.Assignments[player] = pending
+	}
+	complete := r.chainsComplete()
+	r.Mutex.Unlock()
+
+	if complete {
+		r.sendChainReveal()
+		return
+	}
+	r.sendTelephonePage()
+}
+
+// Reveals every chain to all clients via the pub/sub channel and records the round.
+// Telephone rounds are not scored, so players ready up for the next round from the reveal.
+// Rounds that were already revealed are skipped.
+func (r *Room) sendChainReveal() {
+	r.Mutex.Lock()
+	if r.State != relaying {
+		r.Mutex.Unlock()
+		return
+	}
+	crd := &chainRevealData{Question: r.Round.Question, Constraint: r.Round.Constraint}
+	for _, ch := range r.Chains {
+		if len(ch.Links) > 0 {
+			crd.Chains = append(crd.Chains, *ch)
+		}
+	}
+	r.State = scoring
+	record := r.getTelephoneRecord()
+	r.Mutex.Unlock()
+
+	r.storeRoundRecord(record)
+	err := r.publishPage(chainReveal, crd)
+	if err != nil {
+		log.Printf("Error publishing chain reveal: %v", err)
+	}
+}
+
+// Builds the round history record of a finished telephone round. Every link of every
+// chain is a submission. Telephone rounds are not scored, so nobody wins or scores points.
+// Must be called with the room's mutex held.
+func (r *Room) getTelephoneRecord() *RoundRecord {
+	record := &RoundRecord{
+		Number:           r.Round.Number,
+		Question:         r.Round.Question,
+		QuestionAuthorID: r.Round.QuestionAuthorID,
+		Submissions:      make([]SubmissionRecord, 0, len(r.Chains)*r.ChainLength),
+		StartedAt:        r.Round.StartedAt,
+		EndedAt:          time.Now(),
+	}
+	for _, ch := range r.Chains {
+		for i, link := range ch.Links {
+			record.Submissions = append(record.Submissions, SubmissionRecord{
+				UserID:       link.AuthorID,
+				Username:     link.Author,
+				URL:          link.URL,
+				Prompt:       link.Prompt,
+				ChainOwnerID: ch.OwnerID,
+				Step:         i + 1,
+				SubmittedAt:  link.SubmittedAt,
+			})
+		}
+	}
+	return record
+}
@@ -21,8 +21,8 @@ func generateTemplate(path string, data any) ([]byte, error) {
 }
 
 // Creates the username page from its template.
-func generateUsername() ([]byte, error) {
-	return generateTemplate(filepath.Join("templates", "username.html"), nil)
+func generateUsername(upd *usernamePageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "username.html"), upd)
 }
 
 // Creates the waiting room from its template.
@@ -30,16 +30,106 @@ func generateWaitingPage(wpd *waitingPageData) ([]byte, error) {
 	return generateTemplate(filepath.Join("templates", "waiting-page.html"), wpd)
 }
 
+// Creates the display's waiting room from its template.
+func generateDisplayWaitingPage(wpd *waitingPageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "display-waiting.html"), wpd)
+}
+
+// Creates the display's view of the question players are painting from its template.
+func generateDisplayQuestion(gpd *gamePageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "display-question.html"), gpd)
+}
+
+// Creates the display's gallery of candidates from its template.
+func generateDisplayGallery(vpd *votingPageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "display-gallery.html"), vpd)
+}
+
+// Creates the display's round results from its template.
+func generateDisplayResults(lpd *leaderboardPageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "display-results.html"), lpd)
+}
+
+// Creates the lobby from its template.
+func generateLobbyPage(lpd *lobbyPageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "lobby.html"), lpd)
+}
+
+// Creates the quick-match page from its template.
+func generateQuickMatchPage(qpd *quickMatchPageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "quick-match.html"), qpd)
+}
+
 // Creates the player list from its template.
 func generatePlayerList(pld *playerListData) ([]byte, error) {
 	return generateTemplate(filepath.Join("templates", "player-list.html"), pld)
 }
 
+// Creates the room settings panel from its template.
+func generateSettingsPanel(spd *settingsPanelData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "room-settings.html"), spd)
+}
+
+// Creates a notice from its template.
+func generateNotice(nd *noticeData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "notice.html"), nd)
+}
+
+// Creates the notice for a rejected message from its template.
+func generateRejection(rd *rejectionData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "notice.html"), rd)
+}
+
+// Creates the notice for a message with an unsupported protocol version from its template.
+func generateVersionNotice(vd *versionData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "notice.html"), vd)
+}
+
+// Creates the question page from its template.
+func generateQuestionPage(qpd *questionPageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "question-page.html"), qpd)
+}
+
 // Creates the game page from its template.
 func generateGamePage(gpd *gamePageData) ([]byte, error) {
 	return generateTemplate(filepath.Join("templates", "game-page.html"), gpd)
 }
 
+// Creates the judge's view of the game page from its template.
+func generateJudgePage(gpd *gamePageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "judge-page.html"), gpd)
+}
+
+// Creates the artist's waiting page from its template.
+func generateArtistWaitingPage(gpd *gamePageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "artist-waiting.html"), gpd)
+}
+
+// Creates the spectators' view of the game page from its template.
+func generateSpectatorPage(gpd *gamePageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "spectator-page.html"), gpd)
+}
+
+// Creates the telephone page from its template.
+func generateTelephonePage(tpd *telephonePageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "telephone-page.html"), tpd)
+}
+
+// Creates the chain reveal page from its template.
+func generateChainReveal(crd *chainRevealData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "chain-reveal.html"), crd)
+}
+
+// Creates the guessing page from its template.
+func generateGuessingPage(gpd *guessingPageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "guessing-page.html"), gpd)
+}
+
+// Creates the huddle page from its template.
+func generateHuddlePage(hpd *huddlePageData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "huddle-page.html"), hpd)
+}
+
 // Creates the voting page from its template.
 func generateVotingPage(vpd *votingPageData) ([]byte, error) {
 	return generateTemplate(filepath.Join("templates", "voting-page.html"), vpd)
@@ -54,3 +144,18 @@ func generatePicturePreview(ipd *imagePreviewData) ([]byte, error) {
 func generateLeaderboardPage(lpd *leaderboardPageData) ([]byte, error) {
 	return generateTemplate(filepath.Join("templates", "leaderboard.html"), lpd)
 }
+
+// Creates the chat panel from its template.
+func generateChatPanel(cpd *chatPanelData) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "chat.html"), cpd)
+}
+
+// Creates a chat message to add to the chat panel from its template.
+func generateChatMessage(entry *chatEntry) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "chat-message.html"), entry)
+}
+
+// Creates a reaction to add to the chat panel or a picture from its template.
+func generateReaction(reaction *reactionEntry) ([]byte, error) {
+	return generateTemplate(filepath.Join("templates", "reaction.html"), reaction)
+}
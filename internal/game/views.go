@@ -0,0 +1,136 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Renders the page published by the room as the view this client should see.
+// Rooms publish the data for a page and each client decides how to present it,
+// so that players with different roles in a round can see different views.
+func (c *Client) renderView(event gameEvent, data string) ([]byte, error) {
+	switch event {
+	case newPlayerList:
+		pld := &playerListData{}
+		err := json.Unmarshal([]byte(data), pld)
+		if err != nil {
+			return nil, err
+		}
+		return generatePlayerList(pld)
+	case askPage, questionVotePage:
+		qpd := &questionPageData{}
+		err := json.Unmarshal([]byte(data), qpd)
+		if err != nil {
+			return nil, err
+		}
+		qpd.UserID = c.UserID
+		qpd.Spectating = c.isWatching()
+		return generateQuestionPage(qpd)
+	case enterGame:
+		gpd := &gamePageData{}
+		err := json.Unmarshal([]byte(data), gpd)
+		if err != nil {
+			return nil, err
+		}
+		gpd.Team = findTeam(gpd.Teams, c.UserID)
+		gpd.IsCaptain = gpd.Team != nil && gpd.Team.CaptainID == c.UserID
+		if c.isDisplay() {
+			gpd.Countdown = displayCountdown
+			return generateDisplayQuestion(gpd)
+		}
+		if c.isWatching() {
+			return generateSpectatorPage(gpd)
+		}
+		if gpd.JudgeID == c.UserID {
+			return generateJudgePage(gpd)
+		}
+		if gpd.ArtistID != "" && gpd.ArtistID != c.UserID {
+			return generateArtistWaitingPage(gpd)
+		}
+		gpd.Compact = c.hasDisplay()
+		return generateGamePage(gpd)
+	case telephonePage:
+		tpd := &telephonePageData{}
+		err := json.Unmarshal([]byte(data), tpd)
+		if err != nil {
+			return nil, err
+		}
+		tpd.Assignment = tpd.Assignments[c.UserID]
+		tpd.Spectating = c.isWatching()
+		return generateTelephonePage(tpd)
+	case chainReveal:
+		crd := &chainRevealData{}
+		err := json.Unmarshal([]byte(data), crd)
+		if err != nil {
+			return nil, err
+		}
+		crd.Spectating = c.isWatching()
+		return generateChainReveal(crd)
+	case guessPage:
+		gpd := &guessingPageData{}
+		err := json.Unmarshal([]byte(data), gpd)
+		if err != nil {
+			return nil, err
+		}
+		gpd.IsArtist = gpd.ArtistID == c.UserID
+		gpd.Spectating = c.isWatching()
+		return generateGuessingPage(gpd)
+	case huddlePage:
+		hpd := &huddlePageData{}
+		err := json.Unmarshal([]byte(data), hpd)
+		if err != nil {
+			return nil, err
+		}
+		for i := range hpd.Teams {
+			if hpd.Teams[i].hasMember(c.UserID) {
+				hpd.Team = &hpd.Teams[i]
+			}
+		}
+		return generateHuddlePage(hpd)
+	case votePage:
+		vpd := &votingPageData{}
+		err := json.Unmarshal([]byte(data), vpd)
+		if err != nil {
+			return nil, err
+		}
+		if c.isDisplay() {
+			return generateDisplayGallery(vpd)
+		}
+		vpd.Spectating = c.isWatching()
+		vpd.Reactions = reactionEmoji
+		vpd.Choices = vpd.Candidates
+		vpd.excludeOwnTeam(c.UserID)
+		vpd.CanVote = (vpd.JudgeID == "" || vpd.JudgeID == c.UserID) && vpd.ArtistID != c.UserID &&
+			!vpd.Spectating
+		return generateVotingPage(vpd)
+	case sendLeaderboard:
+		lpd := &leaderboardPageData{}
+		err := json.Unmarshal([]byte(data), lpd)
+		if err != nil {
+			return nil, err
+		}
+		if c.isDisplay() {
+			return generateDisplayResults(lpd)
+		}
+		lpd.Spectating = c.isWatching()
+		lpd.Compact = !lpd.Spectating && c.hasDisplay()
+		return generateLeaderboardPage(lpd)
+	case newChatMessage:
+		entry := &chatEntry{}
+		err := json.Unmarshal([]byte(data), entry)
+		if err != nil {
+			return nil, err
+		}
+		entry.Own = entry.UserID == c.UserID
+		return generateChatMessage(entry)
+	case newReaction:
+		reaction := &reactionEntry{}
+		err := json.Unmarshal([]byte(data), reaction)
+		if err != nil {
+			return nil, err
+		}
+		return generateReaction(reaction)
+	default:
+		return nil, fmt.Errorf("No view for event %s", event)
+	}
+}
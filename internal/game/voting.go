@@ -0,0 +1,174 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// A type that represents how players cast their votes.
+type votingMode string
+
+const (
+	singleVote votingMode = "single" // Pick one picture
+	rankedVote votingMode = "ranked" // Rank the top pictures
+	budgetVote votingMode = "budget" // Spread a budget of points across pictures
+)
+
+// The voting modes in the order they are offered in the room settings.
+var votingModes = []votingMode{singleVote, rankedVote, budgetVote}
+
+// Points given to the first, second and third choices of a ranked ballot.
+var rankedChoicePoints = []int{3, 2, 1}
+
+// The number of points each player may spread across pictures in budget mode.
+const voteBudget = 5
+
+// Returns a short human readable explanation of the voting mode.
+func (m votingMode) description() string {
+	switch m {
+	case rankedVote:
+		return fmt.Sprintf("Rank your top %d pictures", len(rankedChoicePoints))
+	case budgetVote:
+		return fmt.Sprintf("Spread %d points across pictures", voteBudget)
+	default:
+		return "Vote for one picture"
+	}
+}
+
+// Reports whether m is a known voting mode.
+func (m votingMode) valid() bool {
+	for _, mode := range votingModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the number of places a ranked ballot must fill for the number of candidates.
+func rankCount(candidates int) int {
+	return min(len(rankedChoicePoints), candidates)
+}
+
+// Lists the places on a ranked ballot for the number of candidates.
+func rankList(candidates int) []int {
+	ranks := make([]int, 0, rankCount(candidates))
+	for rank := 1; rank <= rankCount(candidates); rank++ {
+		ranks = append(ranks, rank)
+	}
+	return ranks
+}
+
+// Leaves the voter's own team's pictures out of the pictures they rank.
+func (vpd *votingPageData) excludeOwnTeam(userID string) {
+	own := vpd.OwnCandidates[userID]
+	if len(own) == 0 {
+		return
+	}
+	vpd.Choices = make([]Candidate, 0, len(vpd.Candidates))
+	for _, candidate := range vpd.Candidates {
+		if !slices.Contains(own, candidate.ID) {
+			vpd.Choices = append(vpd.Choices, candidate)
+		}
+	}
+	vpd.Ranks = rankList(len(vpd.Choices))
+}
+
+// Gets the name of the form input for the nth choice on a ranked ballot.
+func rankField(rank int) string {
+	return fmt.Sprintf("rank-%d", rank)
+}
+
+// Gets the name of the form input for a candidate's points on a budget ballot.
+func budgetField(candidateID string) string {
+	return fmt.Sprintf("points-%s", candidateID)
+}
+
+// The ballot as submitted on the voting page.
+// Choice holds the single vote and Fields holds the inputs of the other modes.
+type ballotForm struct {
+	Choice string              `json:"choice"`
+	Fields map[string][]string `json:"fields"`
+}
+
+// Validates the shape of a submitted ballot against the voting mode and the
+// candidates on the voting page. Returns the resulting ballot.
+func parseBallot(mode votingMode, form *ballotForm, candidates []Candidate) (Ballot, error) {
+	known := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		known[candidate.ID] = true
+	}
+
+	switch mode {
+	case rankedVote:
+		return parseRankedBallot(form, known)
+	case budgetVote:
+		return parseBudgetBallot(form, known)
+	default:
+		if !known[form.Choice] {
+			return nil, errors.New("Please pick one of the pictures")
+		}
+		return Ballot{form.Choice: 1}, nil
+	}
+}
+
+// Gets the single value of a form input. Errors if the input is missing or repeated.
+func singleField(fields map[string][]string, name string) (string, error) {
+	values := fields[name]
+	if len(values) != 1 {
+		return "", fmt.Errorf("Expected one value for %s", name)
+	}
+	return values[0], nil
+}
+
+// Builds a ranked ballot. Every place must be filled with a different picture.
+func parseRankedBallot(form *ballotForm, known map[string]bool) (Ballot, error) {
+	ballot := make(Ballot)
+	for rank := 1; rank <= rankCount(len(known)); rank++ {
+		candidateID, err := singleField(form.Fields, rankField(rank))
+		if err != nil || !known[candidateID] {
+			return nil, fmt.Errorf("Please pick a picture for choice #%d", rank)
+		}
+		if _, duplicate := ballot[candidateID]; duplicate {
+			return nil, errors.New("Each picture can only be ranked once")
+		}
+		ballot[candidateID] = rankedChoicePoints[rank-1]
+	}
+	return ballot, nil
+}
+
+// Builds a budget ballot. Points must be whole, non-negative and add up to at
+// most the budget, with at least one point spent.
+func parseBudgetBallot(form *ballotForm, known map[string]bool) (Ballot, error) {
+	ballot := make(Ballot)
+	total := 0
+	for candidateID := range known {
+		if len(form.Fields[budgetField(candidateID)]) == 0 {
+			continue
+		}
+		value, err := singleField(form.Fields, budgetField(candidateID))
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			continue
+		}
+		points, err := strconv.Atoi(value)
+		if err != nil || points < 0 {
+			return nil, errors.New("Points must be whole numbers of zero or more")
+		}
+		if points > 0 {
+			ballot[candidateID] = points
+		}
+		total += points
+	}
+	if total == 0 {
+		return nil, errors.New("Please give at least one point")
+	}
+	if total > voteBudget {
+		return nil, fmt.Errorf("You can only give %d points in total", voteBudget)
+	}
+	return ballot, nil
+}
@@ -0,0 +1,108 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// Builds a room in the voting state of a ranked round, with two players on each team.
+// The first player of each team is its captain and submits the team's picture.
+func newRankedTeamRoom(t *testing.T, teams int) *Room {
+	t.Helper()
+	r := newRoom("p0")
+	r.Settings.VotingMode = rankedVote
+	r.Settings.Teams = teams
+	r.Captains = make([]string, teams)
+	r.Round = newRound(1, "What is the best pet?")
+	for i := 0; i < teams*2; i++ {
+		userID := fmt.Sprintf("p%d", i)
+		r.Players[userID] = userID
+		r.TurnOrder = append(r.TurnOrder, userID)
+		r.Teams[userID] = i % teams
+		if i < teams {
+			r.Captains[i] = userID
+			r.Round.addSubmission(userID, "https://example.com/"+userID+".png", "a cat")
+		}
+	}
+	r.Round.makeCandidates()
+	r.State = voting
+	return r
+}
+
+// Gets the voting page as a player's client sees it after it is published.
+func votingPageFor(t *testing.T, r *Room, userID string) *votingPageData {
+	t.Helper()
+	data, err := json.Marshal(r.getVotingPageData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	vpd := &votingPageData{}
+	err = json.Unmarshal(data, vpd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vpd.Choices = vpd.Candidates
+	vpd.excludeOwnTeam(userID)
+	return vpd
+}
+
+func TestRankedVotingWithTeams(t *testing.T) {
+	for _, teams := range []int{2, 3} {
+		t.Run(fmt.Sprintf("%d teams", teams), func(t *testing.T) {
+			r := newRankedTeamRoom(t, teams)
+			for userID := range r.Players {
+				if !r.isRequired(userID) {
+					t.Fatalf("%s should vote", userID)
+				}
+				vpd := votingPageFor(t, r, userID)
+				if len(vpd.Ranks) != teams-1 {
+					t.Fatalf("%s ranks %d pictures, want %d", userID, len(vpd.Ranks), teams-1)
+				}
+				form := &ballotForm{Fields: make(map[string][]string)}
+				for i, rank := range vpd.Ranks {
+					form.Fields[rankField(rank)] = []string{vpd.Choices[i].ID}
+				}
+				ballot, err := r.checkBallot(userID, form)
+				if err != nil {
+					t.Fatalf("%s's ballot was rejected: %v", userID, err)
+				}
+				r.Round.addBallot(userID, ballot)
+			}
+
+			if len(r.Round.Ballots) != len(r.Players) {
+				t.Fatalf("got %d ballots, want %d", len(r.Round.Ballots), len(r.Players))
+			}
+			scores, _ := scoreRound([]ScoringRule{votesReceivedRule{}}, r.Round.result(r.getPlayers()))
+			for _, captainID := range r.Captains {
+				if scores[captainID] == 0 {
+					t.Errorf("team of %s got no votes", captainID)
+				}
+			}
+		})
+	}
+}
+
+func TestRankedVotingRejectsOwnTeam(t *testing.T) {
+	r := newRankedTeamRoom(t, 3)
+	own := r.getOwnTeamCandidates()["p3"]
+	if len(own) != 1 {
+		t.Fatalf("got own candidates %v, want one", own)
+	}
+	var other string
+	for _, candidate := range r.Round.Candidates {
+		if !slices.Contains(own, candidate.ID) {
+			other = candidate.ID
+			break
+		}
+	}
+	form := &ballotForm{Fields: map[string][]string{
+		rankField(1): {own[0]},
+		rankField(2): {other},
+	}}
+	_, err := r.checkBallot("p3", form)
+	if err == nil {
+		t.Fatal("ballot ranking the voter's own team was accepted")
+	}
+}
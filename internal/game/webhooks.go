@@ -0,0 +1,288 @@
+package game
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A URL that is sent the lifecycle events it subscribes to as signed JSON.
+// Subscriptions without events are sent every event.
+type WebhookSubscription struct {
+	URL    string
+	Events []LifecycleEventType
+	Secret string
+}
+
+// Reports whether the subscription wants events of the given type.
+func (s *WebhookSubscription) wants(eventType LifecycleEventType) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, eventType)
+}
+
+const (
+	webhookTimeout      = 10 * time.Second // How long a receiver has to respond
+	webhookPollInterval = time.Second      // How often the queue is checked for due deliveries
+	webhookBatchSize    = 20               // The most deliveries claimed at each check
+	webhookMaxAttempts  = 6                // The number of attempts before a delivery is dropped
+	webhookRetryDelay   = 10 * time.Second // The wait before the first retry, doubled for each retry after
+	webhookLogLength    = 1000             // The number of attempts kept in the delivery log
+)
+
+// The headers sent with every webhook. The signature is the hex encoded
+// HMAC-SHA256 of the timestamp, a period and the body, keyed with the
+// subscription's secret and prefixed with "sha256=".
+const (
+	webhookSignatureHeader = "X-Prompt-And-Paint-Signature"
+	webhookTimestampHeader = "X-Prompt-And-Paint-Timestamp"
+	webhookEventHeader     = "X-Prompt-And-Paint-Event"
+	webhookDeliveryHeader  = "X-Prompt-And-Paint-Delivery"
+)
+
+// The webhooks this server sends events to. Set once at startup.
+var webhookSubscriptions []WebhookSubscription
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// Keeps the webhook deliveries waiting to be sent and the log of attempts.
+type webhookStore interface {
+	// Adds a delivery to the retry queue, due at the given time.
+	schedule(ctx context.Context, delivery *webhookDelivery, due time.Time) error
+	// Adds an attempt to the delivery log, which keeps the latest attempts.
+	logAttempt(ctx context.Context, entry *webhookLogEntry) error
+}
+
+// Keeps webhook deliveries in the database, so any server sharing it may send them.
+type redisWebhookStore struct{}
+
+// Where webhook deliveries are queued and logged.
+var webhooks webhookStore = redisWebhookStore{}
+
+// A lifecycle event waiting to be sent to a webhook, as stored in the retry queue.
+type webhookDelivery struct {
+	ID      string             `json:"id"`
+	URL     string             `json:"url"`
+	Event   LifecycleEventType `json:"event"`
+	Payload json.RawMessage    `json:"payload"`
+	Attempt int                `json:"attempt"`
+}
+
+// A single attempt to send a webhook, as recorded in the delivery log.
+type webhookLogEntry struct {
+	DeliveryID string             `json:"deliveryId"`
+	URL        string             `json:"url"`
+	Event      LifecycleEventType `json:"event"`
+	Attempt    int                `json:"attempt"`
+	Status     int                `json:"status,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Delivered  bool               `json:"delivered"`
+	Time       time.Time          `json:"time"`
+}
+
+// Checks the webhook subscriptions and starts sending them lifecycle events.
+// Deliveries are queued in the database and retried with backoff, so any
+// server sharing the database may send them.
+func SetupWebhooks(subscriptions []WebhookSubscription) error {
+	for _, sub := range subscriptions {
+		webhookURL, err := url.Parse(sub.URL)
+		if err != nil || (webhookURL.Scheme != "https" && webhookURL.Scheme != "http") {
+			return fmt.Errorf("Invalid webhook URL: %s", sub.URL)
+		}
+		if sub.Secret == "" {
+			return fmt.Errorf("Webhook %s has no secret", sub.URL)
+		}
+		for _, eventType := range sub.Events {
+			if !slices.Contains(LifecycleEventTypes, eventType) {
+				return fmt.Errorf("Webhook %s subscribes to unknown event %s", sub.URL, eventType)
+			}
+		}
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+	webhookSubscriptions = subscriptions
+	OnLifecycleEvent(queueWebhooks)
+	go runWebhookQueue(context.Background())
+	return nil
+}
+
+// Finds the subscription for a webhook URL. Returns false if the URL is no longer configured.
+func findWebhook(webhookURL string) (*WebhookSubscription, bool) {
+	for i := range webhookSubscriptions {
+		if webhookSubscriptions[i].URL == webhookURL {
+			return &webhookSubscriptions[i], true
+		}
+	}
+	return nil, false
+}
+
+// Queues a delivery of the event to every webhook that subscribes to it.
+func queueWebhooks(event *LifecycleEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding lifecycle event: %v", err)
+		return
+	}
+	for _, sub := range webhookSubscriptions {
+		if !sub.wants(event.Type) {
+			continue
+		}
+		delivery := &webhookDelivery{
+			ID:      uuid.NewString(),
+			URL:     sub.URL,
+			Event:   event.Type,
+			Payload: payload,
+		}
+		err := webhooks.schedule(context.Background(), delivery, time.Now())
+		if err != nil {
+			log.Printf("Error queueing webhook: %v", err)
+		}
+	}
+}
+
+func (redisWebhookStore) schedule(ctx context.Context, delivery *webhookDelivery, due time.Time) error {
+	deliveryJSON, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	return scheduleRedisSortedSet(ctx, string(webhookQueue), string(deliveryJSON), float64(due.UnixMilli()))
+}
+
+// Sends the deliveries in the retry queue as they come due, until ctx is done.
+func runWebhookQueue(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sendDueWebhooks(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Claims the deliveries that are due and sends each of them.
+// A delivery claimed by another server is skipped.
+func sendDueWebhooks(ctx context.Context) {
+	due, err := getRedisSortedSetUpTo(ctx, string(webhookQueue), float64(time.Now().UnixMilli()), webhookBatchSize)
+	if err != nil {
+		log.Printf("Error fetching due webhooks: %v", err)
+		return
+	}
+	for _, deliveryJSON := range due {
+		claimed, err := claimRedisSortedSetMember(ctx, string(webhookQueue), deliveryJSON)
+		if err != nil {
+			log.Printf("Error claiming webhook: %v", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		delivery := &webhookDelivery{}
+		err = json.Unmarshal([]byte(deliveryJSON), delivery)
+		if err != nil {
+			log.Printf("Error parsing queued webhook: %v", err)
+			continue
+		}
+		go attemptWebhook(ctx, delivery)
+	}
+}
+
+// Sends a delivery, records the attempt in the delivery log and queues a
+// retry if the attempt failed. Deliveries are dropped after the last attempt.
+func attemptWebhook(ctx context.Context, delivery *webhookDelivery) {
+	sub, ok := findWebhook(delivery.URL)
+	if !ok {
+		log.Printf("Error dropping webhook for unknown URL %s", delivery.URL)
+		return
+	}
+	delivery.Attempt++
+	status, err := sendWebhook(ctx, sub, delivery)
+	entry := &webhookLogEntry{
+		DeliveryID: delivery.ID,
+		URL:        delivery.URL,
+		Event:      delivery.Event,
+		Attempt:    delivery.Attempt,
+		Status:     status,
+		Delivered:  err == nil,
+		Time:       time.Now(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	logErr := webhooks.logAttempt(ctx, entry)
+	if logErr != nil {
+		log.Printf("Error logging webhook attempt: %v", logErr)
+	}
+	if err == nil {
+		return
+	}
+
+	if delivery.Attempt >= webhookMaxAttempts {
+		log.Printf("Error giving up on webhook %s to %s: %v", delivery.ID, delivery.URL, err)
+		return
+	}
+	retryDelay := webhookRetryDelay << (delivery.Attempt - 1)
+	err = webhooks.schedule(ctx, delivery, time.Now().Add(retryDelay))
+	if err != nil {
+		log.Printf("Error queueing webhook retry: %v", err)
+	}
+}
+
+// Signs the body of a webhook sent at the given Unix timestamp.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Posts a delivery to its webhook. Returns the response status, if there was a
+// response, and an error unless the receiver accepted the delivery.
+func sendWebhook(ctx context.Context, sub *WebhookSubscription, delivery *webhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "prompt-and-paint-webhooks")
+	req.Header.Set(webhookEventHeader, string(delivery.Event))
+	req.Header.Set(webhookDeliveryHeader, delivery.ID)
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, signWebhook(sub.Secret, timestamp, delivery.Payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, errors.New("Unexpected response: " + resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (redisWebhookStore) logAttempt(ctx context.Context, entry *webhookLogEntry) error {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return pushCappedRedisList(ctx, string(webhookLog), entryJSON, webhookLogLength)
+}
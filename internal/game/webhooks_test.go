@@ -0,0 +1,204 @@
+package game
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A delivery waiting in the fake webhook queue.
+type scheduledWebhook struct {
+	Delivery webhookDelivery
+	Due      time.Time
+}
+
+// Keeps webhook deliveries in memory for tests.
+type fakeWebhookStore struct {
+	mutex     sync.Mutex
+	scheduled []scheduledWebhook
+	attempts  []webhookLogEntry
+}
+
+func (s *fakeWebhookStore) schedule(ctx context.Context, delivery *webhookDelivery, due time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scheduled = append(s.scheduled, scheduledWebhook{Delivery: *delivery, Due: due})
+	return nil
+}
+
+func (s *fakeWebhookStore) logAttempt(ctx context.Context, entry *webhookLogEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attempts = append(s.attempts, *entry)
+	return nil
+}
+
+// Takes the deliveries queued since the last call.
+func (s *fakeWebhookStore) takeScheduled() []scheduledWebhook {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	scheduled := s.scheduled
+	s.scheduled = nil
+	return scheduled
+}
+
+// Sends webhooks to a receiver that answers with the given status, and keeps
+// the deliveries in memory. Everything is restored when the test ends.
+func useWebhookReceiver(t *testing.T, status int, receive func(r *http.Request, body []byte)) (*WebhookSubscription, *fakeWebhookStore) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading webhook body: %v", err)
+		}
+		if receive != nil {
+			receive(r, body)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	store := &fakeWebhookStore{}
+	previousStore, previousSubscriptions := webhooks, webhookSubscriptions
+	webhooks = store
+	webhookSubscriptions = []WebhookSubscription{{URL: server.URL, Secret: "webhook-secret"}}
+	t.Cleanup(func() {
+		webhooks, webhookSubscriptions = previousStore, previousSubscriptions
+	})
+	return &webhookSubscriptions[0], store
+}
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"type":"room.created"}`)
+	mac := hmac.New(sha256.New, []byte("webhook-secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := signWebhook("webhook-secret", "1700000000", body); got != want {
+		t.Errorf("got signature %s, want %s", got, want)
+	}
+	if got := signWebhook("other-secret", "1700000000", body); got == want {
+		t.Error("signature does not depend on the secret")
+	}
+	if got := signWebhook("webhook-secret", "1700000001", body); got == want {
+		t.Error("signature does not depend on the timestamp")
+	}
+}
+
+func TestSendWebhookHeaders(t *testing.T) {
+	var header http.Header
+	var received []byte
+	sub, _ := useWebhookReceiver(t, http.StatusNoContent, func(r *http.Request, body []byte) {
+		header = r.Header.Clone()
+		received = body
+	})
+	delivery := &webhookDelivery{
+		ID:      "delivery-1",
+		URL:     sub.URL,
+		Event:   RoomCreated,
+		Payload: []byte(`{"type":"room.created"}`),
+	}
+
+	status, err := sendWebhook(context.Background(), sub, delivery)
+	if err != nil {
+		t.Fatalf("sending webhook: %v", err)
+	}
+	if status != http.StatusNoContent {
+		t.Errorf("got status %d, want %d", status, http.StatusNoContent)
+	}
+	if string(received) != string(delivery.Payload) {
+		t.Errorf("got body %s, want %s", received, delivery.Payload)
+	}
+	if got := header.Get(webhookEventHeader); got != string(RoomCreated) {
+		t.Errorf("got event header %q, want %q", got, RoomCreated)
+	}
+	if got := header.Get(webhookDeliveryHeader); got != delivery.ID {
+		t.Errorf("got delivery header %q, want %q", got, delivery.ID)
+	}
+	timestamp := header.Get(webhookTimestampHeader)
+	if timestamp == "" {
+		t.Fatal("missing timestamp header")
+	}
+	if got, want := header.Get(webhookSignatureHeader), signWebhook(sub.Secret, timestamp, received); got != want {
+		t.Errorf("got signature header %q, want %q", got, want)
+	}
+}
+
+func TestWebhookDelivered(t *testing.T) {
+	sub, store := useWebhookReceiver(t, http.StatusOK, nil)
+	attemptWebhook(context.Background(), &webhookDelivery{ID: "delivery-1", URL: sub.URL, Event: RoomCreated, Payload: []byte(`{}`)})
+
+	if scheduled := store.takeScheduled(); len(scheduled) != 0 {
+		t.Errorf("delivered webhook was queued again: %+v", scheduled)
+	}
+	if len(store.attempts) != 1 || !store.attempts[0].Delivered || store.attempts[0].Status != http.StatusOK {
+		t.Errorf("got attempts %+v, want one delivered attempt", store.attempts)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	sub, store := useWebhookReceiver(t, http.StatusInternalServerError, nil)
+	delivery := &webhookDelivery{ID: "delivery-1", URL: sub.URL, Event: RoundFinished, Payload: []byte(`{}`)}
+
+	for attempt := 1; attempt < webhookMaxAttempts; attempt++ {
+		before := time.Now()
+		attemptWebhook(context.Background(), delivery)
+		after := time.Now()
+
+		scheduled := store.takeScheduled()
+		if len(scheduled) != 1 {
+			t.Fatalf("attempt %d: got %d retries queued, want 1", attempt, len(scheduled))
+		}
+		retry := scheduled[0]
+		if retry.Delivery.Attempt != attempt {
+			t.Errorf("attempt %d: retry records attempt %d", attempt, retry.Delivery.Attempt)
+		}
+		delay := webhookRetryDelay << (attempt - 1)
+		if retry.Due.Before(before.Add(delay)) || retry.Due.After(after.Add(delay)) {
+			t.Errorf("attempt %d: retry due in %v, want %v", attempt, retry.Due.Sub(before), delay)
+		}
+		delivery = &retry.Delivery
+	}
+
+	attemptWebhook(context.Background(), delivery)
+	if scheduled := store.takeScheduled(); len(scheduled) != 0 {
+		t.Errorf("got %d retries after the last attempt, want the delivery dropped", len(scheduled))
+	}
+	if len(store.attempts) != webhookMaxAttempts {
+		t.Fatalf("got %d attempts logged, want %d", len(store.attempts), webhookMaxAttempts)
+	}
+	for i, entry := range store.attempts {
+		if entry.Delivered || entry.Status != http.StatusInternalServerError || entry.Attempt != i+1 {
+			t.Errorf("got attempt %+v, want failed attempt %d", entry, i+1)
+		}
+	}
+}
+
+func TestSetupWebhooksValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		sub  WebhookSubscription
+		want string
+	}{
+		{"non-http URL", WebhookSubscription{URL: "ftp://example.com/hook", Secret: "secret"}, "Invalid webhook URL"},
+		{"relative URL", WebhookSubscription{URL: "/hook", Secret: "secret"}, "Invalid webhook URL"},
+		{"missing secret", WebhookSubscription{URL: "https://example.com/hook"}, "has no secret"},
+		{"unknown event", WebhookSubscription{URL: "https://example.com/hook", Secret: "secret", Events: []LifecycleEventType{"room.painted"}}, "unknown event"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := SetupWebhooks([]WebhookSubscription{test.sub})
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one containing %q", err, test.want)
+			}
+		})
+	}
+}